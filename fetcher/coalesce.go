@@ -0,0 +1,87 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package fetcher
+
+import (
+	"context"
+	stderrors "errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WithNegativeCache caches failed fetches of remote sources for the given
+// TTL, so that a broken upstream is not requested again by every caller.
+// Errors caused by the caller's context are not cached.
+func WithNegativeCache(ttl time.Duration) Modifier {
+	return func(o *opts) {
+		o.negativeTTL = max(0, ttl)
+	}
+}
+
+// Metrics counts how remote fetches were served.
+type Metrics struct {
+	hits, misses, coalesced, negativeHits atomic.Uint64
+}
+
+// Hits is the number of fetches served from the cache.
+func (m *Metrics) Hits() uint64 { return m.hits.Load() }
+
+// Misses is the number of fetches which requested the source.
+func (m *Metrics) Misses() uint64 { return m.misses.Load() }
+
+// Coalesced is the number of fetches which waited for a concurrent fetch of
+// the same source instead of requesting it themselves.
+func (m *Metrics) Coalesced() uint64 { return m.coalesced.Load() }
+
+// NegativeHits is the number of fetches which returned a cached failure.
+func (m *Metrics) NegativeHits() uint64 { return m.negativeHits.Load() }
+
+// Metrics returns the fetcher's metrics.
+func (f *Fetcher) Metrics() *Metrics {
+	return &f.metrics
+}
+
+type negativeEntry struct {
+	err   error
+	until time.Time
+}
+
+// fetchRemote deduplicates concurrent fetches of the same source, and returns
+// cached failures while they are not expired. The fetch is done by load.
+func (f *Fetcher) fetchRemote(ctx context.Context, source string, load func(context.Context) ([]byte, error)) ([]byte, error) {
+	if v, ok := f.negative.Load(source); ok {
+		if e := v.(negativeEntry); time.Now().Before(e.until) {
+			f.metrics.negativeHits.Add(1)
+			return nil, e.err
+		}
+		f.negative.Delete(source)
+	}
+
+	var leader atomic.Bool
+	ch := f.group.DoChan(source, func() (any, error) {
+		leader.Store(true)
+		// The fetch is shared by all callers, so it must not be canceled
+		// when the first caller goes away.
+		b, err := load(context.WithoutCancel(ctx))
+		if err != nil && f.negativeTTL > 0 && !stderrors.Is(err, context.Canceled) && !stderrors.Is(err, context.DeadlineExceeded) {
+			f.negative.Store(source, negativeEntry{err: err, until: time.Now().Add(f.negativeTTL)})
+		}
+		return b, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, errors.WithStack(ctx.Err())
+	case res := <-ch:
+		if !leader.Load() {
+			f.metrics.coalesced.Add(1)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return cloneBytes(res.Val.([]byte)), nil
+	}
+}
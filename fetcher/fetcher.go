@@ -12,33 +12,51 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/ristretto/v2"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/ory/x/httpx"
+	"github.com/ory/x/osx"
 )
 
-// Fetcher is able to load file contents from http, https, file, and base64 locations.
+// Fetcher is able to load file contents from http, https, file, and base64
+// locations, and from custom schemes registered with WithSchemeHandler.
 type Fetcher struct {
-	hc      *retryablehttp.Client
-	limit   int64
-	cache   *ristretto.Cache[[]byte, []byte]
-	ttl     time.Duration
-	schemes []string
+	hc        *retryablehttp.Client
+	limit     int64
+	cache     *ristretto.Cache[[]byte, []byte]
+	ttl       time.Duration
+	schemes   []string
+	httpCache bool
+	digests   []string
+	registry  *osx.SchemeRegistry
+	builtins  *osx.SchemeRegistry
+
+	negativeTTL  time.Duration
+	revalidating sync.Map
+	negative     sync.Map
+	group        singleflight.Group
+	metrics      Metrics
 }
 
 type opts struct {
-	hc      *retryablehttp.Client
-	limit   int64
-	cache   *ristretto.Cache[[]byte, []byte]
-	ttl     time.Duration
-	schemes []string
+	hc          *retryablehttp.Client
+	limit       int64
+	cache       *ristretto.Cache[[]byte, []byte]
+	ttl         time.Duration
+	schemes     []string
+	httpCache   bool
+	negativeTTL time.Duration
+	digests     []string
+	registry    *osx.SchemeRegistry
 }
 
 var ErrUnknownScheme = stderrors.New("unknown scheme")
@@ -51,7 +69,8 @@ func WithClient(hc *retryablehttp.Client) Modifier {
 }
 
 // WithMaxHTTPMaxBytes reads at most limit bytes from the HTTP response body,
-// returning bytes.ErrToLarge if the limit would be exceeded.
+// returning bytes.ErrToLarge if the limit would be exceeded. The limit also
+// applies to sources read by scheme handlers.
 func WithMaxHTTPMaxBytes(limit int64) Modifier {
 	return func(o *opts) {
 		o.limit = limit
@@ -68,16 +87,42 @@ func WithCache(cache *ristretto.Cache[[]byte, []byte], ttl time.Duration) Modifi
 	}
 }
 
+// WithSchemeRegistry dispatches sources with the schemes registered in the
+// registry to their handlers, which take precedence over the built-in
+// handlers. Registered schemes are allowed unless WithAllowedSchemes is set.
+// Like remote sources, they are cached, coalesced, and limited in size.
+func WithSchemeRegistry(r *osx.SchemeRegistry) Modifier {
+	return func(o *opts) {
+		o.registry = r
+	}
+}
+
+// WithSchemeHandler registers the handler for the scheme, which is then
+// allowed unless WithAllowedSchemes is set. If a registry was set with
+// WithSchemeRegistry, the handler is registered there.
+func WithSchemeHandler(scheme string, h osx.SchemeHandler) Modifier {
+	return func(o *opts) {
+		if o.registry == nil {
+			o.registry = osx.NewSchemeRegistry()
+		}
+		o.registry.Register(scheme, h)
+	}
+}
+
+// WithAllowedSchemes restricts the sources to the given schemes, including
+// schemes with a registered handler. By default, the built-in schemes http,
+// https, file, and base64, and all registered schemes are allowed.
 func WithAllowedSchemes(schemes ...string) Modifier {
 	return func(o *opts) {
-		o.schemes = slices.Clone(schemes)
+		o.schemes = append([]string{}, schemes...)
 	}
 }
 
+var builtinSchemes = []string{"http", "https", "file", "base64"}
+
 func newOpts() *opts {
 	return &opts{
-		hc:      httpx.NewResilientClient(),
-		schemes: []string{"http", "https", "file", "base64"},
+		hc: httpx.NewResilientClient(),
 	}
 }
 
@@ -100,7 +145,20 @@ func NewFetcher(opts ...Modifier) *Fetcher {
 	for _, f := range opts {
 		f(o)
 	}
-	return &Fetcher{hc: o.hc, limit: o.limit, cache: o.cache, ttl: o.ttl, schemes: o.schemes}
+	f := &Fetcher{hc: o.hc, limit: o.limit, cache: o.cache, ttl: o.ttl, schemes: o.schemes, httpCache: o.httpCache, negativeTTL: o.negativeTTL, digests: o.digests, registry: o.registry}
+
+	remote := osx.SchemeHandlerFunc(func(ctx context.Context, u *url.URL) ([]byte, error) {
+		source := u.String()
+		return f.fetchRemote(ctx, source, func(ctx context.Context) ([]byte, error) {
+			return f.fetchRemoteCached(ctx, source)
+		})
+	})
+	f.builtins = osx.NewSchemeRegistry()
+	f.builtins.Register("http", remote)
+	f.builtins.Register("https", remote)
+	f.builtins.Register("file", osx.FileSchemeHandler())
+	f.builtins.Register("base64", osx.Base64SchemeHandler(base64.StdEncoding))
+	return f
 }
 
 // FetchContext fetches the file contents from the source and allows to pass a
@@ -115,36 +173,115 @@ func (f *Fetcher) FetchContext(ctx context.Context, source string) (*bytes.Buffe
 
 // FetchBytes fetches the file contents from the source and allows to pass a
 // context that is used for HTTP requests.
+//
+// The source may pin the digest of its contents in its fragment, e.g.
+// https://example.com/schema.json#sha256-<base64 digest>, see
+// WithExpectedDigest.
 func (f *Fetcher) FetchBytes(ctx context.Context, source string) ([]byte, error) {
-	if !slices.ContainsFunc(f.schemes, func(scheme string) bool {
-		return strings.HasPrefix(source, scheme+"://")
-	}) {
-		return nil, errors.WithStack(fmt.Errorf("%w: in source %q: allowed schemes: %s", ErrUnknownScheme, redactedSource(source), strings.Join(f.schemes, ", ")))
-	}
-	switch {
-	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
-		return f.fetchRemote(ctx, source)
-	case strings.HasPrefix(source, "file://"):
-		b, err := os.ReadFile(strings.TrimPrefix(source, "file://"))
+	source, digests, err := splitDigest(source)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := f.fetchBytes(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyIntegrity(source, b, append(digests, f.digests...)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (f *Fetcher) fetchBytes(ctx context.Context, source string) ([]byte, error) {
+	// The scheme is checked before the handler is looked up, so that a
+	// registered handler can not bypass WithAllowedSchemes.
+	scheme, _, ok := strings.Cut(source, "://")
+	if !ok || !f.allowed(scheme) {
+		return nil, errors.WithStack(fmt.Errorf("%w: in source %q: allowed schemes: %s", ErrUnknownScheme, redactedSource(source), strings.Join(f.allowedSchemes(), ", ")))
+	}
+
+	if h, ok := f.registry.Lookup(scheme); ok {
+		return f.fetchRemote(ctx, source, func(ctx context.Context) ([]byte, error) {
+			return f.fetchSchemeCached(ctx, source, h)
+		})
+	}
+
+	h, ok := f.builtins.Lookup(scheme)
+	if !ok {
+		return nil, errors.Wrap(ErrUnknownScheme, "unknown scheme in source: "+redactedSource(source))
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse source: %s", redactedSource(source))
+	}
+	return h.ReadSource(ctx, u)
+}
+
+func (f *Fetcher) allowed(scheme string) bool {
+	return slices.ContainsFunc(f.allowedSchemes(), func(allowed string) bool {
+		return strings.EqualFold(allowed, scheme)
+	})
+}
+
+func (f *Fetcher) allowedSchemes() []string {
+	if f.schemes != nil {
+		return f.schemes
+	}
+	return append(slices.Clone(builtinSchemes), f.registry.Schemes()...)
+}
+
+func (f *Fetcher) fetchRemoteCached(ctx context.Context, source string) ([]byte, error) {
+	if f.cache != nil && f.httpCache {
+		return f.fetchRemoteHTTPCache(ctx, source)
+	}
+	return f.fetchCached(source, func() ([]byte, error) {
+		res, err := f.get(ctx, source, nil)
 		if err != nil {
-			return nil, errors.Wrapf(err, "read file: %s", redactedSource(source))
+			return nil, err
 		}
-		return b, nil
-	case strings.HasPrefix(source, "base64://"):
-		src, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(source, "base64://"))
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("expected http response status code 200 but got %d when fetching: %s", res.StatusCode, redactedSource(source))
+		}
+
+		return f.readBody(res)
+	})
+}
+
+// fetchSchemeCached reads the source with the scheme handler. The cache is
+// used for its TTL even with WithHTTPCacheSemantics, because handlers return
+// no caching headers.
+func (f *Fetcher) fetchSchemeCached(ctx context.Context, source string, h osx.SchemeHandler) ([]byte, error) {
+	return f.fetchCached(source, func() ([]byte, error) {
+		u, err := url.Parse(source)
 		if err != nil {
-			return nil, errors.Wrapf(err, "base64decode: %s", redactedSource(source))
+			return nil, errors.Wrapf(err, "parse source: %s", redactedSource(source))
 		}
-		return src, nil
-	default:
-		return nil, errors.Wrap(ErrUnknownScheme, "unknown scheme in source: "+redactedSource(source))
-	}
+		if f.limit > 0 {
+			ctx = osx.ContextWithMaxSourceBytes(ctx, f.limit)
+		}
+
+		b, err := h.ReadSource(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		// Not every handler respects the limit while reading.
+		if f.limit > 0 && int64(len(b)) > f.limit {
+			return nil, errors.WithStack(bytes.ErrTooLarge)
+		}
+		return b, nil
+	})
 }
 
-func (f *Fetcher) fetchRemote(ctx context.Context, source string) (b []byte, err error) {
+// fetchCached returns the cached contents of the source, or loads and caches
+// them if the cache was set with WithCache.
+func (f *Fetcher) fetchCached(source string, load func() ([]byte, error)) (b []byte, err error) {
 	if f.cache != nil {
 		cacheKey := sha256.Sum256([]byte(source))
 		if v, ok := f.cache.Get(cacheKey[:]); ok {
+			f.metrics.hits.Add(1)
 			b = make([]byte, len(v))
 			copy(b, v)
 			return b, nil
@@ -157,21 +294,27 @@ func (f *Fetcher) fetchRemote(ctx context.Context, source string) (b []byte, err
 			}
 		}()
 	}
+	f.metrics.misses.Add(1)
+
+	return load()
+}
 
+func (f *Fetcher) get(ctx context.Context, source string, header http.Header) (*http.Response, error) {
 	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, source, nil)
 	if err != nil {
 		return nil, errors.Wrapf(err, "new request: %s", redactedSource(source))
 	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
 	res, err := f.hc.Do(req)
 	if err != nil {
 		return nil, errors.Wrap(err, redactedSource(source))
 	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("expected http response status code 200 but got %d when fetching: %s", res.StatusCode, redactedSource(source))
-	}
+	return res, nil
+}
 
+func (f *Fetcher) readBody(res *http.Response) ([]byte, error) {
 	if f.limit > 0 {
 		var buf bytes.Buffer
 		n, err := io.Copy(&buf, io.LimitReader(res.Body, f.limit+1))
@@ -0,0 +1,225 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package fetcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/cachex"
+)
+
+// WithHTTPCacheSemantics makes the cache set with WithCache honour the caching
+// headers of HTTP responses instead of caching every response for the cache's
+// TTL:
+//
+//   - Responses are fresh for the Cache-Control max-age, or until the Expires
+//     header. Responses without either are fresh for the cache's TTL.
+//   - Responses with Cache-Control no-store are not cached.
+//   - Stale responses are revalidated with If-None-Match and
+//     If-Modified-Since if they carry an ETag or Last-Modified header.
+//   - Within the Cache-Control stale-while-revalidate window, stale responses
+//     are served while they are refreshed in the background.
+//   - Within the Cache-Control stale-if-error window, stale responses are
+//     served if the upstream fails.
+func WithHTTPCacheSemantics() Modifier {
+	return func(o *opts) {
+		o.httpCache = true
+	}
+}
+
+// httpCacheEntry is stored in the cache in HTTP cache mode.
+type httpCacheEntry struct {
+	Body                 []byte        `json:"body"`
+	ETag                 string        `json:"etag,omitempty"`
+	LastModified         string        `json:"last_modified,omitempty"`
+	Expires              time.Time     `json:"expires"`
+	StaleWhileRevalidate time.Duration `json:"stale_while_revalidate,omitempty"`
+	StaleIfError         time.Duration `json:"stale_if_error,omitempty"`
+}
+
+func (e *httpCacheEntry) isFresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+func (e *httpCacheEntry) mayServeWhileRevalidating(now time.Time) bool {
+	return now.Before(e.Expires.Add(e.StaleWhileRevalidate))
+}
+
+func (e *httpCacheEntry) mayServeOnError(now time.Time) bool {
+	return now.Before(e.Expires.Add(e.StaleIfError))
+}
+
+func httpCacheKey(source string) []byte {
+	key := sha256.Sum256([]byte("http-cache\x00" + source))
+	return key[:]
+}
+
+func (f *Fetcher) fetchRemoteHTTPCache(ctx context.Context, source string) ([]byte, error) {
+	now := time.Now()
+	cached := f.loadHTTPCacheEntry(source)
+	if cached != nil {
+		if cached.isFresh(now) {
+			f.metrics.hits.Add(1)
+			return cloneBytes(cached.Body), nil
+		}
+		if cached.mayServeWhileRevalidating(now) {
+			f.metrics.hits.Add(1)
+			if _, running := f.revalidating.LoadOrStore(source, struct{}{}); !running {
+				go func() {
+					defer f.revalidating.Delete(source)
+					_, _ = f.revalidate(context.WithoutCancel(ctx), source, cached)
+				}()
+			}
+			return cloneBytes(cached.Body), nil
+		}
+	}
+
+	f.metrics.misses.Add(1)
+	b, err := f.revalidate(ctx, source, cached)
+	if err != nil {
+		if cached != nil && cached.mayServeOnError(now) {
+			return cloneBytes(cached.Body), nil
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// revalidate fetches the source, conditionally if the cached entry has
+// validators, and updates the cache.
+func (f *Fetcher) revalidate(ctx context.Context, source string, cached *httpCacheEntry) ([]byte, error) {
+	header := http.Header{}
+	if cached != nil {
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	res, err := f.get(ctx, source, header)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && cached != nil:
+		entry := newHTTPCacheEntry(res, cached.Body, f.ttl)
+		if entry.ETag == "" {
+			entry.ETag = cached.ETag
+		}
+		if entry.LastModified == "" {
+			entry.LastModified = cached.LastModified
+		}
+		f.storeHTTPCacheEntry(source, res, entry)
+		return cloneBytes(cached.Body), nil
+	case res.StatusCode != http.StatusOK:
+		return nil, errors.Errorf("expected http response status code 200 but got %d when fetching: %s", res.StatusCode, redactedSource(source))
+	}
+
+	b, err := f.readBody(res)
+	if err != nil {
+		return nil, err
+	}
+	f.storeHTTPCacheEntry(source, res, newHTTPCacheEntry(res, cloneBytes(b), f.ttl))
+	return b, nil
+}
+
+// newHTTPCacheEntry computes the freshness of the response. Responses without
+// explicit freshness are fresh for the fallback TTL.
+func newHTTPCacheEntry(res *http.Response, body []byte, fallback time.Duration) *httpCacheEntry {
+	cc := cachex.ParseCacheControl(res.Header)
+	now := time.Now()
+
+	entry := &httpCacheEntry{
+		Body:                 body,
+		ETag:                 res.Header.Get("ETag"),
+		LastModified:         res.Header.Get("Last-Modified"),
+		Expires:              now.Add(fallback),
+		StaleWhileRevalidate: directiveSeconds(cc, "stale-while-revalidate"),
+		StaleIfError:         directiveSeconds(cc, "stale-if-error"),
+	}
+
+	if _, ok := cc["no-cache"]; ok {
+		entry.Expires = now
+	} else if _, ok := cc["max-age"]; ok {
+		age := time.Duration(0)
+		if v, err := strconv.Atoi(res.Header.Get("Age")); err == nil && v > 0 {
+			age = time.Duration(v) * time.Second
+		}
+		entry.Expires = now.Add(directiveSeconds(cc, "max-age") - age)
+	} else if v := res.Header.Get("Expires"); v != "" {
+		// Invalid dates, e.g. "0", mean that the response is already expired.
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			entry.Expires = now
+		} else if date, err := http.ParseTime(res.Header.Get("Date")); err == nil {
+			entry.Expires = now.Add(expires.Sub(date))
+		} else {
+			entry.Expires = expires
+		}
+	}
+
+	return entry
+}
+
+func directiveSeconds(cc cachex.CacheControl, directive string) time.Duration {
+	v, err := strconv.Atoi(cc[directive])
+	if err != nil || v < 0 {
+		return 0
+	}
+	return time.Duration(v) * time.Second
+}
+
+func (f *Fetcher) loadHTTPCacheEntry(source string) *httpCacheEntry {
+	v, ok := f.cache.Get(httpCacheKey(source))
+	if !ok {
+		return nil
+	}
+	var entry httpCacheEntry
+	if err := json.Unmarshal(v, &entry); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+func (f *Fetcher) storeHTTPCacheEntry(source string, res *http.Response, entry *httpCacheEntry) {
+	if _, ok := cachex.ParseCacheControl(res.Header)["no-store"]; ok {
+		f.cache.Del(httpCacheKey(source))
+		return
+	}
+
+	// Entries are kept beyond their freshness for as long as they may be
+	// served stale. Entries with validators are also kept for the cache's TTL
+	// so that they can be revalidated cheaply.
+	ttl := time.Until(entry.Expires) + max(entry.StaleWhileRevalidate, entry.StaleIfError)
+	if entry.ETag != "" || entry.LastModified != "" {
+		ttl = max(ttl, f.ttl)
+	}
+	if ttl <= 0 {
+		f.cache.Del(httpCacheKey(source))
+		return
+	}
+
+	v, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	f.cache.SetWithTTL(httpCacheKey(source), v, int64(len(v)), ttl)
+}
+
+func cloneBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
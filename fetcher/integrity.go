@@ -0,0 +1,115 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package fetcher
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	stderrors "errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrIntegrityMismatch = stderrors.New("content does not match the pinned digest")
+	ErrInvalidDigest     = stderrors.New("invalid digest")
+
+	isDigest = regexp.MustCompile(`^(sha256|sha384|sha512)-[A-Za-z0-9+/_-]+={0,2}$`)
+)
+
+// IntegrityError is returned if the fetched content does not match a pinned
+// digest. It wraps ErrIntegrityMismatch.
+type IntegrityError struct {
+	// Source is the fetched source, without the pinned digest.
+	Source string
+	// Expected is the pinned digest, e.g. "sha256-<base64 digest>".
+	Expected string
+	// Actual is the digest of the fetched content, using the same algorithm.
+	Actual string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("%s: %s: expected %s but got %s", ErrIntegrityMismatch, redactedSource(e.Source), e.Expected, e.Actual)
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return ErrIntegrityMismatch
+}
+
+// WithExpectedDigest pins the digest of the fetched content in the format
+// used by subresource integrity, e.g. "sha256-<base64 digest>". Supported
+// algorithms are sha256, sha384, and sha512. Content which does not match
+// the digest is rejected with an IntegrityError.
+func WithExpectedDigest(digest string) Modifier {
+	return func(o *opts) {
+		o.digests = append(o.digests, digest)
+	}
+}
+
+// splitDigest removes a digest pinned in the fragment of the source. Fragments
+// which are not digests are left untouched, because they may be part of a
+// file name.
+func splitDigest(source string) (string, []string, error) {
+	i := strings.LastIndex(source, "#")
+	if i < 0 {
+		return source, nil, nil
+	}
+	fragment := source[i+1:]
+	if !isDigest.MatchString(fragment) {
+		if strings.HasPrefix(fragment, "sha") && strings.Contains(fragment, "-") {
+			return "", nil, errors.WithStack(fmt.Errorf("%w: %q in source %s", ErrInvalidDigest, fragment, redactedSource(source[:i])))
+		}
+		return source, nil, nil
+	}
+	return source[:i], []string{fragment}, nil
+}
+
+func verifyIntegrity(source string, content []byte, digests []string) error {
+	for _, d := range digests {
+		algorithm, encoded, _ := strings.Cut(d, "-")
+
+		var h hash.Hash
+		switch algorithm {
+		case "sha256":
+			h = sha256.New()
+		case "sha384":
+			h = sha512.New384()
+		case "sha512":
+			h = sha512.New()
+		default:
+			return errors.WithStack(fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidDigest, algorithm))
+		}
+
+		expected, err := decodeDigest(encoded)
+		if err != nil || len(expected) != h.Size() {
+			return errors.WithStack(fmt.Errorf("%w: %q", ErrInvalidDigest, d))
+		}
+
+		h.Write(content)
+		if actual := h.Sum(nil); subtle.ConstantTimeCompare(actual, expected) != 1 {
+			return errors.WithStack(&IntegrityError{
+				Source:   source,
+				Expected: d,
+				Actual:   algorithm + "-" + base64.StdEncoding.EncodeToString(actual),
+			})
+		}
+	}
+	return nil
+}
+
+// decodeDigest accepts both the standard and the URL-safe base64 alphabet,
+// because the latter is easier to embed in URLs.
+func decodeDigest(encoded string) ([]byte, error) {
+	encoded = strings.TrimRight(encoded, "=")
+	if strings.ContainsAny(encoded, "-_") {
+		return base64.RawURLEncoding.DecodeString(encoded)
+	}
+	return base64.RawStdEncoding.DecodeString(encoded)
+}
@@ -0,0 +1,48 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package fetcher
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector collects fetcher metrics.
+type Collector struct {
+	prefix      string
+	metricsFunc func() *Metrics
+}
+
+// NewCollector creates a new Collector.
+//
+// To use this collector, you need to register it with a Prometheus registry:
+//
+//	func main() {
+//		f := fetcher.NewFetcher(fetcher.WithNegativeCache(time.Minute))
+//		collector := fetcher.NewCollector("prefix_", f.Metrics)
+//		prometheus.MustRegister(collector)
+//	}
+func NewCollector(prefix string, metricsFunc func() *Metrics) *Collector {
+	return &Collector{
+		prefix:      prefix,
+		metricsFunc: metricsFunc,
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc(c.prefix+"fetcher_hits", "Total number of fetches served from the cache", nil, nil)
+	ch <- prometheus.NewDesc(c.prefix+"fetcher_misses", "Total number of fetches which requested the source", nil, nil)
+	ch <- prometheus.NewDesc(c.prefix+"fetcher_coalesced", "Total number of fetches which waited for a concurrent fetch of the same source", nil, nil)
+	ch <- prometheus.NewDesc(c.prefix+"fetcher_negative_hits", "Total number of fetches which returned a cached failure", nil, nil)
+}
+
+// Collect is called by the Prometheus registry when collecting metrics.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	metrics := c.metricsFunc()
+	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(c.prefix+"fetcher_hits", "Total number of fetches served from the cache", nil, nil), prometheus.CounterValue, float64(metrics.Hits()))
+	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(c.prefix+"fetcher_misses", "Total number of fetches which requested the source", nil, nil), prometheus.CounterValue, float64(metrics.Misses()))
+	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(c.prefix+"fetcher_coalesced", "Total number of fetches which waited for a concurrent fetch of the same source", nil, nil), prometheus.CounterValue, float64(metrics.Coalesced()))
+	ch <- prometheus.MustNewConstMetric(prometheus.NewDesc(c.prefix+"fetcher_negative_hits", "Total number of fetches which returned a cached failure", nil, nil), prometheus.CounterValue, float64(metrics.NegativeHits()))
+}
@@ -0,0 +1,210 @@
+package hasherx
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/inhies/go-bytesize"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// DefaultCalibrationRuns is the number of times each parameter set is
+	// benchmarked during calibration.
+	DefaultCalibrationRuns = 3
+
+	minArgon2Memory     = 16 * bytesize.MB
+	maxArgon2Iterations = 1 << 10
+	pbkdf2Probe         = 10_000
+)
+
+var ErrCalibrationTargetUnreachable = errors.New("the target latency can not be reached on this host with the given limits")
+
+type (
+	// CalibrationOption configures a calibration run.
+	CalibrationOption func(*calibrationOptions)
+
+	calibrationOptions struct {
+		runs        int
+		parallelism uint8
+		saltLength  uint32
+		keyLength   uint32
+	}
+)
+
+// WithCalibrationRuns sets how often each parameter set is benchmarked. The
+// mean duration of all runs is compared with the target latency.
+func WithCalibrationRuns(runs int) CalibrationOption {
+	return func(o *calibrationOptions) {
+		o.runs = max(1, runs)
+	}
+}
+
+// WithCalibrationParallelism sets the Argon2 parallelism. Defaults to twice the
+// number of CPUs.
+func WithCalibrationParallelism(p uint8) CalibrationOption {
+	return func(o *calibrationOptions) {
+		o.parallelism = max(1, p)
+	}
+}
+
+func newCalibrationOptions(opts ...CalibrationOption) *calibrationOptions {
+	o := &calibrationOptions{
+		runs:        DefaultCalibrationRuns,
+		parallelism: uint8(min(2*runtime.NumCPU(), math.MaxUint8)), //nolint:gosec // bounded by math.MaxUint8
+		saltLength:  16,
+		keyLength:   32,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// CalibrateArgon2 benchmarks Argon2id on the current host and returns the
+// parameters which get closest to, without exceeding, the target latency while
+// using at most maxMemory. Memory is preferred over iterations: the memory is
+// only reduced below maxMemory if a single iteration is already too slow.
+func CalibrateArgon2(ctx context.Context, targetLatency time.Duration, maxMemory bytesize.ByteSize, opts ...CalibrationOption) (*Argon2Config, error) {
+	o := newCalibrationOptions(opts...)
+	c := &Argon2Config{
+		Memory:          maxMemory,
+		Iterations:      1,
+		Parallelism:     o.parallelism,
+		SaltLength:      o.saltLength,
+		KeyLength:       o.keyLength,
+		DedicatedMemory: maxMemory,
+	}
+
+	measure := func() (time.Duration, time.Duration, error) {
+		mem, err := toKB(c.Memory)
+		if err != nil {
+			return 0, 0, err
+		}
+		salt := make([]byte, c.SaltLength)
+		return benchmark(ctx, o.runs, func() {
+			_ = argon2.IDKey([]byte("password"), salt, c.Iterations, mem, c.Parallelism, c.KeyLength)
+		})
+	}
+
+	// Reduce the memory until a single iteration fits the target.
+	mean, deviation, err := measure()
+	if err != nil {
+		return nil, err
+	}
+	for mean > targetLatency {
+		if c.Memory/2 < minArgon2Memory {
+			return nil, errors.WithStack(ErrCalibrationTargetUnreachable)
+		}
+		c.Memory /= 2
+		if mean, deviation, err = measure(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Increase the iterations for as long as the target is not exceeded.
+	for c.Iterations < maxArgon2Iterations {
+		c.Iterations++
+		m, d, err := measure()
+		if err != nil {
+			return nil, err
+		}
+		if m > targetLatency {
+			c.Iterations--
+			break
+		}
+		mean, deviation = m, d
+	}
+
+	c.ExpectedDuration = mean
+	c.ExpectedDeviation = deviation
+	return c, nil
+}
+
+// CalibrateBcrypt benchmarks bcrypt on the current host and returns the highest
+// cost whose latency does not exceed the target.
+func CalibrateBcrypt(ctx context.Context, targetLatency time.Duration, opts ...CalibrationOption) (*BCryptConfig, error) {
+	o := newCalibrationOptions(opts...)
+
+	cost := bcrypt.MinCost
+	for ; cost <= bcrypt.MaxCost; cost++ {
+		mean, _, err := benchmark(ctx, o.runs, func() {
+			_, _ = bcrypt.GenerateFromPassword([]byte("password"), cost)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if mean > targetLatency {
+			break
+		}
+	}
+
+	if cost == bcrypt.MinCost {
+		return nil, errors.WithStack(ErrCalibrationTargetUnreachable)
+	}
+	return &BCryptConfig{Cost: uint32(cost - 1)}, nil //nolint:gosec // bounded by bcrypt.MaxCost
+}
+
+// CalibratePBKDF2 benchmarks PBKDF2 with the given algorithm on the current host
+// and returns the number of iterations which gets closest to the target
+// latency. PBKDF2 scales linearly with the number of iterations, so the
+// iterations are extrapolated from a single probe. The algorithm must be one of
+// sha1, sha224, sha256, sha384, or sha512.
+func CalibratePBKDF2(ctx context.Context, targetLatency time.Duration, algorithm string, opts ...CalibrationOption) (*PBKDF2Config, error) {
+	switch algorithm {
+	case "sha1", "sha224", "sha256", "sha384", "sha512":
+	default:
+		// The hasher would silently fall back to sha256 for the returned
+		// config.
+		return nil, errors.Wrapf(ErrUnknownHashAlgorithm, "unsupported pbkdf2 algorithm %q", algorithm)
+	}
+
+	o := newCalibrationOptions(opts...)
+	c := &PBKDF2Config{
+		Algorithm:  algorithm,
+		SaltLength: o.saltLength,
+		KeyLength:  o.keyLength,
+	}
+
+	salt := make([]byte, c.SaltLength)
+	mean, _, err := benchmark(ctx, o.runs, func() {
+		_ = pbkdf2.Key([]byte("password"), salt, pbkdf2Probe, int(c.KeyLength), getPseudorandomFunctionForPbkdf2(algorithm))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	iterations := float64(pbkdf2Probe) * float64(targetLatency) / float64(max(mean, 1))
+	if iterations < 1 {
+		return nil, errors.WithStack(ErrCalibrationTargetUnreachable)
+	}
+	c.Iterations = uint32(min(iterations, math.MaxUint32))
+	return c, nil
+}
+
+// benchmark runs fn the given number of times and returns the mean duration and
+// the largest deviation from it.
+func benchmark(ctx context.Context, runs int, fn func()) (mean, deviation time.Duration, err error) {
+	durations := make([]time.Duration, runs)
+	var total time.Duration
+	for i := range durations {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, errors.WithStack(err)
+		}
+		start := time.Now()
+		fn()
+		durations[i] = time.Since(start)
+		total += durations[i]
+	}
+
+	mean = total / time.Duration(runs)
+	for _, d := range durations {
+		deviation = max(deviation, (d - mean).Abs())
+	}
+	return mean, deviation, nil
+}
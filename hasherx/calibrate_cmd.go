@@ -0,0 +1,97 @@
+package hasherx
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/inhies/go-bytesize"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/cmdx"
+)
+
+// NewCalibrateCommand returns a command which benchmarks a hash algorithm on the
+// current host and prints the recommended configuration as YAML.
+func NewCalibrateCommand() *cobra.Command {
+	var (
+		targetLatency time.Duration
+		maxMemory     = bytesize.GB
+		runs          int
+		parallelism   uint8
+		algorithm     string
+	)
+	cmd := &cobra.Command{
+		Use:   "calibrate <argon2|bcrypt|pbkdf2>",
+		Short: "Computes hasher parameters which match the target latency on this host",
+		Long: `Benchmarks the given hash algorithm on this host and prints the parameters which get closest to the target latency
+without exceeding it. Run this command on the same instance type the service is deployed to, ideally under production load.
+
+For Argon2, memory is preferred over iterations: the memory is only reduced below --max-memory if a single iteration
+exceeds the target latency.`,
+		ValidArgs: []string{"argon2", "bcrypt", "pbkdf2"},
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := []CalibrationOption{WithCalibrationRuns(runs)}
+			if parallelism > 0 {
+				opts = append(opts, WithCalibrationParallelism(parallelism))
+			}
+
+			var config yaml.MapSlice
+			switch args[0] {
+			case "argon2":
+				c, err := CalibrateArgon2(cmd.Context(), targetLatency, maxMemory, opts...)
+				if err != nil {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unable to calibrate Argon2: %s\n", err)
+					return cmdx.FailSilently(cmd)
+				}
+				config = yaml.MapSlice{
+					{Key: "memory", Value: c.Memory.String()},
+					{Key: "iterations", Value: c.Iterations},
+					{Key: "parallelism", Value: c.Parallelism},
+					{Key: "salt_length", Value: c.SaltLength},
+					{Key: "key_length", Value: c.KeyLength},
+					{Key: "expected_duration", Value: c.ExpectedDuration.Round(time.Millisecond).String()},
+					{Key: "expected_deviation", Value: c.ExpectedDeviation.Round(time.Millisecond).String()},
+					{Key: "dedicated_memory", Value: c.DedicatedMemory.String()},
+				}
+			case "bcrypt":
+				c, err := CalibrateBcrypt(cmd.Context(), targetLatency, opts...)
+				if err != nil {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unable to calibrate bcrypt: %s\n", err)
+					return cmdx.FailSilently(cmd)
+				}
+				config = yaml.MapSlice{{Key: "cost", Value: c.Cost}}
+			case "pbkdf2":
+				c, err := CalibratePBKDF2(cmd.Context(), targetLatency, algorithm, opts...)
+				if err != nil {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unable to calibrate PBKDF2: %s\n", err)
+					return cmdx.FailSilently(cmd)
+				}
+				config = yaml.MapSlice{
+					{Key: "algorithm", Value: c.Algorithm},
+					{Key: "iterations", Value: c.Iterations},
+					{Key: "salt_length", Value: c.SaltLength},
+					{Key: "key_length", Value: c.KeyLength},
+				}
+			}
+
+			out, err := yaml.Marshal(yaml.MapSlice{{Key: "hashers", Value: yaml.MapSlice{
+				{Key: "algorithm", Value: args[0]},
+				{Key: args[0], Value: config},
+			}}})
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unable to encode configuration: %s\n", err)
+				return cmdx.FailSilently(cmd)
+			}
+			_, _ = fmt.Fprint(cmd.OutOrStdout(), string(out))
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&targetLatency, "target-latency", 500*time.Millisecond, "The desired duration of a single hash operation.")
+	cmd.Flags().Var(&maxMemory, "max-memory", "The maximum memory Argon2 may use per hash operation.")
+	cmd.Flags().IntVar(&runs, "runs", DefaultCalibrationRuns, "How often to benchmark each parameter set.")
+	cmd.Flags().Uint8Var(&parallelism, "parallelism", 0, "The Argon2 parallelism. Defaults to twice the number of CPUs.")
+	cmd.Flags().StringVar(&algorithm, "pbkdf2-algorithm", "sha256", "The PBKDF2 pseudorandom function, one of sha1, sha224, sha256, sha384, sha512.")
+	return cmd
+}
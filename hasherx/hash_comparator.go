@@ -1,36 +1,92 @@
 package hasherx
 
 import (
+	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1" // #nosec G505 - compatibility for imported passwords
+	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
+	"hash"
 	"math"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
 )
 
-var ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+var (
+	ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+	ErrComparatorExists     = errors.New("a hash comparator with this name is already registered")
+)
+
+type (
+	// Comparator compares passwords with hashes of a specific format.
+	Comparator struct {
+		// Name identifies the hash format, e.g. "bcrypt".
+		Name string
+
+		// Understands returns true if the hash is in this comparator's format.
+		Understands func(hash []byte) bool
+
+		// Compare returns nil if the password matches the hash.
+		Compare func(ctx context.Context, password []byte, hash []byte) error
+	}
+)
+
+var comparators = struct {
+	sync.RWMutex
+	list []Comparator
+}{
+	list: []Comparator{
+		{Name: "bcrypt", Understands: IsBcryptHash, Compare: CompareBcrypt},
+		{Name: "argon2id", Understands: IsArgon2idHash, Compare: CompareArgon2id},
+		{Name: "argon2i", Understands: IsArgon2iHash, Compare: CompareArgon2i},
+		{Name: "pbkdf2", Understands: IsPbkdf2Hash, Compare: ComparePbkdf2},
+		{Name: "scrypt", Understands: IsScryptHash, Compare: CompareScrypt},
+		{Name: "ssha", Understands: IsSSHAHash, Compare: CompareSSHA},
+		{Name: "md5-crypt", Understands: IsMD5CryptHash, Compare: CompareMD5Crypt},
+		{Name: "firescrypt", Understands: IsFirebaseScryptHash, Compare: CompareFirebaseScrypt},
+	},
+}
+
+// RegisterComparator adds a comparator for a custom hash format to the ones
+// used by Compare. Built-in formats always take precedence over registered
+// ones, and registered ones are tried in the order they were added.
+func RegisterComparator(c Comparator) error {
+	comparators.Lock()
+	defer comparators.Unlock()
+
+	for _, existing := range comparators.list {
+		if existing.Name == c.Name {
+			return errors.WithStack(ErrComparatorExists)
+		}
+	}
+	comparators.list = append(comparators.list, c)
+	return nil
+}
 
 // Compare the given password with the given hash.
 func Compare(ctx context.Context, password []byte, hash []byte) error {
-	switch {
-	case IsBcryptHash(hash):
-		return CompareBcrypt(ctx, password, hash)
-	case IsArgon2idHash(hash):
-		return CompareArgon2id(ctx, password, hash)
-	case IsArgon2iHash(hash):
-		return CompareArgon2i(ctx, password, hash)
-	case IsPbkdf2Hash(hash):
-		return ComparePbkdf2(ctx, password, hash)
-	default:
-		return errors.WithStack(ErrUnknownHashAlgorithm)
+	comparators.RLock()
+	list := comparators.list
+	comparators.RUnlock()
+
+	for _, c := range list {
+		if c.Understands(hash) {
+			return c.Compare(ctx, password, hash)
+		}
 	}
+	return errors.WithStack(ErrUnknownHashAlgorithm)
 }
 
 func CompareBcrypt(_ context.Context, password []byte, hash []byte) error {
@@ -116,11 +172,107 @@ func ComparePbkdf2(_ context.Context, password []byte, hash []byte) error {
 	return errors.WithStack(ErrMismatchedHashAndPassword)
 }
 
+func CompareScrypt(_ context.Context, password []byte, hash []byte) error {
+	// Extract the parameters, salt and derived key from the encoded password
+	// hash.
+	p, salt, hash, err := decodeScryptHash(string(hash))
+	if err != nil {
+		return err
+	}
+
+	// Derive the key from the other password using the same parameters.
+	otherHash, err := scrypt.Key(password, salt, int(p.Cost), int(p.Block), int(p.Parallelization), int(p.KeyLength))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Check that the contents of the hashed passwords are identical. Note
+	// that we are using the subtle.ConstantTimeCompare() function for this
+	// to help prevent timing attacks.
+	if subtle.ConstantTimeCompare(hash, otherHash) == 1 {
+		return nil
+	}
+	return errors.WithStack(ErrMismatchedHashAndPassword)
+}
+
+func CompareSSHA(_ context.Context, password []byte, hash []byte) error {
+	hasher, salt, hash, err := decodeSSHAHash(string(hash))
+	if err != nil {
+		return err
+	}
+
+	h := hasher()
+	h.Write(password)
+	h.Write(salt)
+	otherHash := h.Sum(nil)
+
+	// Check that the contents of the hashed passwords are identical. Note
+	// that we are using the subtle.ConstantTimeCompare() function for this
+	// to help prevent timing attacks.
+	if subtle.ConstantTimeCompare(hash, otherHash) == 1 {
+		return nil
+	}
+	return errors.WithStack(ErrMismatchedHashAndPassword)
+}
+
+func CompareMD5Crypt(_ context.Context, password []byte, hash []byte) error {
+	parts := bytes.Split(hash, []byte("$"))
+	if len(parts) != 4 {
+		return errors.WithStack(ErrInvalidHash)
+	}
+
+	otherHash := md5Crypt(password, parts[2])
+
+	// Check that the contents of the hashed passwords are identical. Note
+	// that we are using the subtle.ConstantTimeCompare() function for this
+	// to help prevent timing attacks.
+	if subtle.ConstantTimeCompare(hash, otherHash) == 1 {
+		return nil
+	}
+	return errors.WithStack(ErrMismatchedHashAndPassword)
+}
+
+func CompareFirebaseScrypt(_ context.Context, password []byte, hash []byte) error {
+	// Extract the parameters, salt, salt separator, derived key, and signer
+	// key from the encoded password hash.
+	p, salt, saltSeparator, hash, signerKey, err := decodeFirebaseScryptHash(string(hash))
+	if err != nil {
+		return err
+	}
+
+	// Firebase derives an AES key from the password and then encrypts the
+	// signer key with it.
+	key, err := scrypt.Key(password, append(salt, saltSeparator...), int(p.Cost), int(p.Block), int(p.Parallelization), 32)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	otherHash := make([]byte, len(signerKey))
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(otherHash, signerKey)
+
+	// Check that the contents of the hashed passwords are identical. Note
+	// that we are using the subtle.ConstantTimeCompare() function for this
+	// to help prevent timing attacks.
+	if subtle.ConstantTimeCompare(hash, otherHash) == 1 {
+		return nil
+	}
+	return errors.WithStack(ErrMismatchedHashAndPassword)
+}
+
 var (
-	isBcryptHash   = regexp.MustCompile(`^\$2[abzy]?\$`)
-	isArgon2idHash = regexp.MustCompile(`^\$argon2id\$`)
-	isArgon2iHash  = regexp.MustCompile(`^\$argon2i\$`)
-	isPbkdf2Hash   = regexp.MustCompile(`^\$pbkdf2-sha[0-9]{1,3}\$`)
+	isBcryptHash         = regexp.MustCompile(`^\$2[abzy]?\$`)
+	isArgon2idHash       = regexp.MustCompile(`^\$argon2id\$`)
+	isArgon2iHash        = regexp.MustCompile(`^\$argon2i\$`)
+	isPbkdf2Hash         = regexp.MustCompile(`^\$pbkdf2-sha[0-9]{1,3}\$`)
+	isScryptHash         = regexp.MustCompile(`^\$scrypt\$`)
+	isSSHAHash           = regexp.MustCompile(`^{SSHA(256|512)?}`)
+	isMD5CryptHash       = regexp.MustCompile(`^\$1\$`)
+	isFirebaseScryptHash = regexp.MustCompile(`^\$firescrypt\$`)
 )
 
 func IsBcryptHash(hash []byte) bool {
@@ -139,6 +291,22 @@ func IsPbkdf2Hash(hash []byte) bool {
 	return isPbkdf2Hash.Match(hash)
 }
 
+func IsScryptHash(hash []byte) bool {
+	return isScryptHash.Match(hash)
+}
+
+func IsSSHAHash(hash []byte) bool {
+	return isSSHAHash.Match(hash)
+}
+
+func IsMD5CryptHash(hash []byte) bool {
+	return isMD5CryptHash.Match(hash)
+}
+
+func IsFirebaseScryptHash(hash []byte) bool {
+	return isFirebaseScryptHash.Match(hash)
+}
+
 func decodeArgon2idHash(encodedHash string) (p *Argon2Config, salt, hash []byte, err error) {
 	parts := strings.Split(encodedHash, "$")
 	if len(parts) != 6 {
@@ -225,3 +393,118 @@ func decodePbkdf2Hash(encodedHash string) (p *PBKDF2Config, salt, hash []byte, e
 
 	return p, salt, hash, nil
 }
+
+// scryptParams are the parameters encoded in a scrypt or Firebase scrypt hash.
+type scryptParams struct {
+	// Cost is the CPU/memory cost parameter N.
+	Cost uint32
+	// Block is the block size parameter r.
+	Block uint32
+	// Parallelization is the parallelization parameter p.
+	Parallelization uint32
+	// KeyLength is the length of the derived key.
+	KeyLength uint32
+}
+
+// decodeScryptHash decodes a scrypt encoded password hash.
+// format: $scrypt$ln=<cost>,r=<block>,p=<parallelization>$<salt>$<hash>
+func decodeScryptHash(encodedHash string) (p *scryptParams, salt, hash []byte, err error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	p = new(scryptParams)
+	_, err = fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &p.Cost, &p.Block, &p.Parallelization)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	salt, err = base64.StdEncoding.Strict().DecodeString(parts[3])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	hash, err = base64.StdEncoding.Strict().DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keyLength := uint(len(hash))
+	if keyLength > math.MaxUint32 {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	p.KeyLength = uint32(keyLength)
+
+	return p, salt, hash, nil
+}
+
+// decodeSSHAHash decodes an LDAP-style salted SHA password hash.
+// format: {SSHA}<base64(sha1(password+salt)+salt)>, or {SSHA256} and {SSHA512}
+// for SHA-256 and SHA-512 respectively.
+func decodeSSHAHash(encodedHash string) (hasher func() hash.Hash, salt, hash []byte, err error) {
+	var encoded string
+	switch {
+	case strings.HasPrefix(encodedHash, "{SSHA}"):
+		hasher, encoded = sha1.New, strings.TrimPrefix(encodedHash, "{SSHA}")
+	case strings.HasPrefix(encodedHash, "{SSHA256}"):
+		hasher, encoded = sha256.New, strings.TrimPrefix(encodedHash, "{SSHA256}")
+	case strings.HasPrefix(encodedHash, "{SSHA512}"):
+		hasher, encoded = sha512.New, strings.TrimPrefix(encodedHash, "{SSHA512}")
+	default:
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	size := hasher().Size()
+	if len(decoded) <= size {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	return hasher, decoded[size:], decoded[:size], nil
+}
+
+// decodeFirebaseScryptHash decodes a Firebase scrypt encoded password hash.
+// format: $firescrypt$ln=<mem_cost>,r=<rounds>,p=<parallelization>$<salt>$<hash>$<salt_separator>$<signer_key>
+func decodeFirebaseScryptHash(encodedHash string) (p *scryptParams, salt, saltSeparator, hash, signerKey []byte, err error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 7 {
+		return nil, nil, nil, nil, nil, ErrInvalidHash
+	}
+
+	p = new(scryptParams)
+	_, err = fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &p.Cost, &p.Block, &p.Parallelization)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+	if p.Cost > 31 {
+		return nil, nil, nil, nil, nil, ErrInvalidHash
+	}
+	// Firebase encodes the memory cost as the base-2 logarithm of N.
+	p.Cost = 1 << p.Cost
+
+	salt, err = base64.StdEncoding.Strict().DecodeString(parts[3])
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	hash, err = base64.StdEncoding.Strict().DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	saltSeparator, err = base64.StdEncoding.Strict().DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	signerKey, err = base64.StdEncoding.Strict().DecodeString(parts[6])
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	return p, salt, saltSeparator, hash, signerKey, nil
+}
@@ -82,6 +82,11 @@ func (h *Argon2) Generate(ctx context.Context, password []byte) (_ []byte, err e
 	p := h.c.HasherArgon2Config(ctx)
 	span.SetAttributes(attribute.String("argon2.config", fmt.Sprintf("#%v", p)))
 
+	password, wrap, err := applyPepper(ctx, h.c, password)
+	if err != nil {
+		return nil, err
+	}
+
 	salt := make([]byte, p.SaltLength)
 	if _, err := rand.Read(salt); err != nil {
 		return nil, err
@@ -109,10 +114,10 @@ func (h *Argon2) Generate(ctx context.Context, password []byte) (_ []byte, err e
 		return nil, errors.WithStack(err)
 	}
 
-	return b.Bytes(), nil
+	return wrap(b.Bytes()), nil
 }
 
 // Understands checks if the given hash is in the correct format.
 func (h *Argon2) Understands(hash []byte) bool {
-	return IsArgon2idHash(hash)
+	return IsArgon2idHash(unwrapPepperedHash(hash))
 }
@@ -42,6 +42,11 @@ func (h *Bcrypt) Generate(ctx context.Context, password []byte) (hash []byte, er
 		return nil, err
 	}
 
+	password, wrap, err := applyPepper(ctx, h.c, password)
+	if err != nil {
+		return nil, err
+	}
+
 	cost := int(h.c.HasherBcryptConfig(ctx).Cost)
 	span.SetAttributes(attribute.Int("bcrypt.cost", cost))
 	hash, err = bcrypt.GenerateFromPassword(password, cost)
@@ -49,7 +54,7 @@ func (h *Bcrypt) Generate(ctx context.Context, password []byte) (hash []byte, er
 		return nil, err
 	}
 
-	return hash, nil
+	return wrap(hash), nil
 }
 
 func validateBcryptPasswordLength(password []byte) error {
@@ -64,5 +69,5 @@ func validateBcryptPasswordLength(password []byte) error {
 
 // Understands checks if the given hash is in the correct format.
 func (h *Bcrypt) Understands(hash []byte) bool {
-	return IsBcryptHash(hash)
+	return IsBcryptHash(unwrapPepperedHash(hash))
 }
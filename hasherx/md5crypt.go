@@ -0,0 +1,83 @@
+package hasherx
+
+import (
+	"bytes"
+	"crypto/md5" // #nosec G501 - compatibility for imported passwords
+)
+
+const (
+	md5CryptMagic  = "$1$"
+	md5CryptItoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// md5Crypt implements the FreeBSD MD5-crypt algorithm as used by
+// crypt(3) for hashes starting with "$1$". It returns the encoded
+// hash including the magic prefix and the salt.
+func md5Crypt(password, salt []byte) []byte {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+
+	d := md5.New() // #nosec G401 - compatibility for imported passwords
+	d.Write(password)
+	d.Write([]byte(md5CryptMagic))
+	d.Write(salt)
+
+	alt := md5.New() // #nosec G401 - compatibility for imported passwords
+	alt.Write(password)
+	alt.Write(salt)
+	alt.Write(password)
+	altSum := alt.Sum(nil)
+
+	for i := len(password); i > 0; i -= 16 {
+		d.Write(altSum[:min(i, 16)])
+	}
+
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			d.Write([]byte{0})
+		} else {
+			d.Write(password[:1])
+		}
+	}
+	final := d.Sum(nil)
+
+	for i := range 1000 {
+		r := md5.New() // #nosec G401 - compatibility for imported passwords
+		if i&1 == 1 {
+			r.Write(password)
+		} else {
+			r.Write(final)
+		}
+		if i%3 != 0 {
+			r.Write(salt)
+		}
+		if i%7 != 0 {
+			r.Write(password)
+		}
+		if i&1 == 1 {
+			r.Write(final)
+		} else {
+			r.Write(password)
+		}
+		final = r.Sum(nil)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(md5CryptMagic)
+	b.Write(salt)
+	b.WriteByte('$')
+	for _, i := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		md5CryptTo64(&b, uint(final[i[0]])<<16|uint(final[i[1]])<<8|uint(final[i[2]]), 4)
+	}
+	md5CryptTo64(&b, uint(final[11]), 2)
+
+	return b.Bytes()
+}
+
+func md5CryptTo64(b *bytes.Buffer, v uint, n int) {
+	for range n {
+		b.WriteByte(md5CryptItoa64[v&0x3f])
+		v >>= 6
+	}
+}
@@ -0,0 +1,169 @@
+package hasherx
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrPepperKeyNotFound  = errors.New("the pepper key used for this hash is not configured")
+	ErrInvalidPepperKeyID = errors.New("the pepper key ID must only contain letters, digits, dots, underscores, and hyphens")
+
+	// The key ID is encoded into the hash, so it must not contain the "$"
+	// separator of the hash format.
+	isPepperKeyID  = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	isPepperedHash = regexp.MustCompile(`^\$pepper\$k=[A-Za-z0-9._-]+\$`)
+)
+
+type (
+	// PepperConfig is the configuration for peppering password hashes. The
+	// password is HMAC-SHA256'd with the active pepper key before it is
+	// hashed, and the key ID is encoded in the hash so that keys can be
+	// rotated.
+	PepperConfig struct {
+		// ActiveKeyID is the ID of the key used to pepper new hashes. If
+		// empty, new hashes are not peppered. Key IDs may only contain
+		// letters, digits, dots, underscores, and hyphens.
+		ActiveKeyID string `json:"active_key_id"`
+
+		// Keys maps key IDs to pepper keys. Keys which are no longer active
+		// must be kept for as long as hashes peppered with them exist.
+		Keys map[string][]byte `json:"keys"`
+	}
+
+	// PepperConfigurator is the interface that must be implemented by a
+	// configuration provider to pepper hashes. The Argon2 and bcrypt hashers
+	// pepper passwords if their configurator also implements this interface.
+	PepperConfigurator interface {
+		HasherPepperConfig(ctx context.Context) *PepperConfig
+	}
+
+	pepperContextKey struct{}
+)
+
+func init() {
+	// Registered here because ComparePeppered calls Compare for the inner
+	// hash, which would otherwise be an initialization cycle.
+	comparators.list = append(comparators.list, Comparator{Name: "pepper", Understands: IsPepperedHash, Compare: ComparePeppered})
+}
+
+// WithPepperConfigurator returns a context carrying the pepper configurator
+// which Compare uses to look up the keys of peppered hashes.
+func WithPepperConfigurator(ctx context.Context, c PepperConfigurator) context.Context {
+	return context.WithValue(ctx, pepperContextKey{}, c)
+}
+
+func pepperConfiguratorFromContext(ctx context.Context) (PepperConfigurator, bool) {
+	c, ok := ctx.Value(pepperContextKey{}).(PepperConfigurator)
+	return c, ok
+}
+
+// IsPepperedHash returns true if the hash was generated from a peppered
+// password.
+func IsPepperedHash(hash []byte) bool {
+	return isPepperedHash.Match(hash)
+}
+
+// ComparePeppered compares the password with a peppered hash. The pepper key
+// is looked up by its ID in the PepperConfigurator set with
+// WithPepperConfigurator.
+func ComparePeppered(ctx context.Context, password []byte, hash []byte) error {
+	keyID, inner, err := decodePepperedHash(hash)
+	if err != nil {
+		return err
+	}
+	if IsPepperedHash(inner) {
+		return errors.WithStack(ErrInvalidHash)
+	}
+
+	c, ok := pepperConfiguratorFromContext(ctx)
+	if !ok {
+		return errors.WithStack(ErrPepperKeyNotFound)
+	}
+	conf := c.HasherPepperConfig(ctx)
+	if conf == nil {
+		return errors.WithStack(ErrPepperKeyNotFound)
+	}
+	key, ok := conf.Keys[keyID]
+	if !ok {
+		return errors.WithStack(ErrPepperKeyNotFound)
+	}
+
+	return Compare(ctx, pepperPassword(key, password), inner)
+}
+
+// decodePepperedHash decodes a peppered password hash.
+// format: $pepper$k=<key id>$<hash>
+func decodePepperedHash(hash []byte) (keyID string, inner []byte, err error) {
+	if !IsPepperedHash(hash) {
+		return "", nil, errors.WithStack(ErrInvalidHash)
+	}
+	parts := bytes.SplitN(hash, []byte("$"), 4)
+	return string(bytes.TrimPrefix(parts[2], []byte("k="))), parts[3], nil
+}
+
+// unwrapPepperedHash returns the hash without the pepper prefix, if any.
+func unwrapPepperedHash(hash []byte) []byte {
+	if _, inner, err := decodePepperedHash(hash); err == nil {
+		return inner
+	}
+	return hash
+}
+
+func pepperPassword(key, password []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(password)
+	// The MAC is base64 encoded so that it contains no NUL bytes and stays
+	// well below bcrypt's 72 byte limit.
+	return []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// applyPepper peppers the password if the configurator implements
+// PepperConfigurator and a key is active. It returns the password to hash and
+// a function which encodes the key ID into the resulting hash.
+func applyPepper(ctx context.Context, c any, password []byte) ([]byte, func([]byte) []byte, error) {
+	noop := func(hash []byte) []byte { return hash }
+
+	pc, ok := c.(PepperConfigurator)
+	if !ok {
+		return password, noop, nil
+	}
+	conf := pc.HasherPepperConfig(ctx)
+	if conf == nil || conf.ActiveKeyID == "" {
+		return password, noop, nil
+	}
+	if !isPepperKeyID.MatchString(conf.ActiveKeyID) {
+		return nil, nil, errors.WithStack(ErrInvalidPepperKeyID)
+	}
+	key, ok := conf.Keys[conf.ActiveKeyID]
+	if !ok {
+		return nil, nil, errors.WithStack(ErrPepperKeyNotFound)
+	}
+
+	return pepperPassword(key, password), func(hash []byte) []byte {
+		return append([]byte("$pepper$k="+conf.ActiveKeyID+"$"), hash...)
+	}, nil
+}
+
+// pepperNeedsUpgrade returns true if the hash is not peppered with the key
+// currently active in the configurator.
+func pepperNeedsUpgrade(ctx context.Context, c any, hash []byte) bool {
+	var active string
+	if pc, ok := c.(PepperConfigurator); ok {
+		if conf := pc.HasherPepperConfig(ctx); conf != nil {
+			active = conf.ActiveKeyID
+		}
+	}
+
+	keyID, _, err := decodePepperedHash(hash)
+	if err != nil {
+		return active != ""
+	}
+	return keyID != active
+}
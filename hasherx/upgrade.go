@@ -0,0 +1,141 @@
+package hasherx
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ory/x/otelx"
+)
+
+// UpgradeChecker is implemented by hashers which can tell whether a hash they
+// understand was generated with parameters different from their current
+// configuration.
+type UpgradeChecker interface {
+	// NeedsUpgrade returns true if the hash was generated with parameters
+	// that differ from the hasher's current configuration.
+	NeedsUpgrade(ctx context.Context, hash []byte) (bool, error)
+}
+
+var (
+	_ UpgradeChecker = (*Argon2)(nil)
+	_ UpgradeChecker = (*Bcrypt)(nil)
+	_ UpgradeChecker = (*PBKDF2)(nil)
+)
+
+// NeedsUpgrade returns true if the hash should be replaced by a hash generated
+// by target, either because it uses a different algorithm or because it was
+// generated with outdated parameters.
+func NeedsUpgrade(ctx context.Context, hash []byte, target Hasher) (bool, error) {
+	if !target.Understands(hash) {
+		return true, nil
+	}
+	if c, ok := target.(UpgradeChecker); ok {
+		return c.NeedsUpgrade(ctx, hash)
+	}
+	return false, nil
+}
+
+// CompareAndUpgrade compares the password with the hash just like Compare. If
+// the password matches and the hash needs an upgrade (see NeedsUpgrade), a new
+// hash generated by target is returned and upgraded is true. The caller is
+// responsible for persisting the new hash.
+func CompareAndUpgrade(ctx context.Context, password []byte, hash []byte, target Hasher) (newHash []byte, upgraded bool, err error) {
+	ctx, span := otel.GetTracerProvider().Tracer(tracingComponent).Start(ctx, "hash.CompareAndUpgrade")
+	defer otelx.End(span, &err)
+
+	if _, ok := pepperConfiguratorFromContext(ctx); !ok {
+		if c, ok := hasherConfigurator(target).(PepperConfigurator); ok {
+			ctx = WithPepperConfigurator(ctx, c)
+		}
+	}
+
+	if err := Compare(ctx, password, hash); err != nil {
+		return nil, false, err
+	}
+
+	needsUpgrade, err := NeedsUpgrade(ctx, hash, target)
+	if err != nil {
+		return nil, false, err
+	}
+	span.SetAttributes(attribute.Bool("hash.needs_upgrade", needsUpgrade))
+	if !needsUpgrade {
+		return nil, false, nil
+	}
+
+	newHash, err = target.Generate(ctx, password)
+	if err != nil {
+		return nil, false, err
+	}
+	return newHash, true, nil
+}
+
+func hasherConfigurator(h Hasher) any {
+	switch h := h.(type) {
+	case *Argon2:
+		return h.c
+	case *Bcrypt:
+		return h.c
+	case *PBKDF2:
+		return h.c
+	}
+	return nil
+}
+
+// NeedsUpgrade returns true if the Argon2id hash was generated with a memory,
+// iterations, parallelism, salt length, or key length setting, or a pepper
+// key that differs from the current configuration.
+func (h *Argon2) NeedsUpgrade(ctx context.Context, hash []byte) (bool, error) {
+	if pepperNeedsUpgrade(ctx, h.c, hash) {
+		return true, nil
+	}
+
+	p, _, _, err := decodeArgon2idHash(string(unwrapPepperedHash(hash)))
+	if err != nil {
+		return false, err
+	}
+
+	c := h.c.HasherArgon2Config(ctx)
+	mem, err := toKB(c.Memory)
+	if err != nil {
+		return false, err
+	}
+
+	return uint64(p.Memory) != uint64(mem) ||
+		p.Iterations != c.Iterations ||
+		p.Parallelism != c.Parallelism ||
+		p.SaltLength != c.SaltLength ||
+		p.KeyLength != c.KeyLength, nil
+}
+
+// NeedsUpgrade returns true if the bcrypt hash was generated with a cost or a
+// pepper key that differs from the current configuration.
+func (h *Bcrypt) NeedsUpgrade(ctx context.Context, hash []byte) (bool, error) {
+	if pepperNeedsUpgrade(ctx, h.c, hash) {
+		return true, nil
+	}
+
+	cost, err := bcrypt.Cost(unwrapPepperedHash(hash))
+	if err != nil {
+		return false, err
+	}
+	return uint32(cost) != h.c.HasherBcryptConfig(ctx).Cost, nil //nolint:gosec // bcrypt costs are always between 4 and 31
+}
+
+// NeedsUpgrade returns true if the PBKDF2 hash was generated with an algorithm,
+// iteration count, salt length, or key length that differs from the current
+// configuration.
+func (h *PBKDF2) NeedsUpgrade(ctx context.Context, hash []byte) (bool, error) {
+	p, _, _, err := decodePbkdf2Hash(string(hash))
+	if err != nil {
+		return false, err
+	}
+
+	c := h.c.HasherPBKDF2Config(ctx)
+	return p.Algorithm != c.Algorithm ||
+		p.Iterations != c.Iterations ||
+		p.SaltLength != c.SaltLength ||
+		p.KeyLength != c.KeyLength, nil
+}
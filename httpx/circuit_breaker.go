@@ -0,0 +1,338 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package httpx
+
+import (
+	"context"
+	stderrs "errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrCircuitOpen is returned without sending the request if the circuit
+	// breaker of the request's host is open.
+	ErrCircuitOpen = stderrs.New("circuit breaker is open")
+	// ErrHostConcurrencyLimit is returned without sending the request if the
+	// maximum number of in-flight requests to the request's host is reached.
+	ErrHostConcurrencyLimit = stderrs.New("maximum number of in-flight requests to host reached")
+)
+
+// CircuitBreakerState is the state of a host's circuit breaker.
+type CircuitBreakerState string
+
+const (
+	// CircuitClosed lets all requests pass.
+	CircuitClosed CircuitBreakerState = "closed"
+	// CircuitOpen rejects all requests until the cool-down has passed.
+	CircuitOpen CircuitBreakerState = "open"
+	// CircuitHalfOpen lets a single probe request pass, which closes the
+	// circuit on success and opens it again on failure.
+	CircuitHalfOpen CircuitBreakerState = "half-open"
+)
+
+// CircuitBreakerConfig configures the per-host circuit breaker of the
+// resilient client. A request fails if it returns an error or a 5xx status
+// code. Requests canceled or timed out by the caller do not count.
+type CircuitBreakerConfig struct {
+	// FailureRatio opens the circuit once this ratio of requests in the
+	// current window failed. Defaults to 0.5.
+	FailureRatio float64
+	// MinRequests is the number of requests in the current window below
+	// which the circuit is never opened. Defaults to 10.
+	MinRequests int
+	// Window is the duration after which the request counts are reset.
+	// Defaults to one minute.
+	Window time.Duration
+	// CoolDown is the duration the circuit stays open before a probe request
+	// is let through. Defaults to 30 seconds.
+	CoolDown time.Duration
+}
+
+func (c CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if c.FailureRatio <= 0 || c.FailureRatio > 1 {
+		c.FailureRatio = 0.5
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 10
+	}
+	if c.Window <= 0 {
+		c.Window = time.Minute
+	}
+	if c.CoolDown <= 0 {
+		c.CoolDown = 30 * time.Second
+	}
+	return c
+}
+
+// ResilientClientWithCircuitBreaker enables a circuit breaker per host, so that
+// requests to a host which is down fail fast instead of waiting through all
+// retries. Requests rejected by an open circuit are not retried.
+func ResilientClientWithCircuitBreaker(c CircuitBreakerConfig) ResilientOptions {
+	return func(o *resilientOptions) {
+		c = c.withDefaults()
+		o.circuitBreaker = &c
+	}
+}
+
+// ResilientClientWithMaxInFlightPerHost limits the number of concurrent
+// requests per host. A request is in flight until its response body is closed.
+// Requests exceeding the limit fail immediately with ErrHostConcurrencyLimit
+// and are not retried.
+func ResilientClientWithMaxInFlightPerHost(n int) ResilientOptions {
+	return func(o *resilientOptions) { o.maxInFlightPerHost = n }
+}
+
+var (
+	circuitBreakerTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ory_x_httpx_circuit_breaker_transitions_total",
+		Help: "Counts the state transitions of the resilient client's per-host circuit breakers",
+	}, []string{"state"})
+	CircuitBreakerTransitions prometheus.Collector = circuitBreakerTransitions
+
+	hostGuardRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ory_x_httpx_host_guard_rejections_total",
+		Help: "Counts the requests rejected by an open circuit breaker or the per-host concurrency limit",
+	}, []string{"reason"})
+	HostGuardRejections prometheus.Collector = hostGuardRejections
+)
+
+var _ http.RoundTripper = (*hostGuardRoundTripper)(nil)
+
+// hostGuardRoundTripper enforces the circuit breaker and the concurrency limit
+// per host. The hosts are not labeled in the metrics, because the resilient
+// client may talk to arbitrary hosts, e.g. for webhooks. They are attached to
+// the trace events instead.
+type hostGuardRoundTripper struct {
+	next        http.RoundTripper
+	breaker     *CircuitBreakerConfig
+	maxInFlight int
+	now         func() time.Time
+
+	mu        sync.Mutex
+	hosts     map[string]*hostGuard
+	lastPrune time.Time
+}
+
+type hostGuard struct {
+	inFlight int
+	lastUsed time.Time
+
+	state         CircuitBreakerState
+	windowStart   time.Time
+	total, failed int
+	openedAt      time.Time
+	probeInFlight bool
+}
+
+// requestOutcome is the result of a request as seen by the circuit breaker.
+type requestOutcome int
+
+const (
+	outcomeSuccess requestOutcome = iota
+	outcomeFailure
+	// outcomeCanceled is a request which was canceled or timed out by the
+	// caller. It says nothing about the health of the host.
+	outcomeCanceled
+)
+
+func newHostGuardRoundTripper(next http.RoundTripper, breaker *CircuitBreakerConfig, maxInFlight int) *hostGuardRoundTripper {
+	return &hostGuardRoundTripper{
+		next:        next,
+		breaker:     breaker,
+		maxInFlight: maxInFlight,
+		now:         time.Now,
+		hosts:       map[string]*hostGuard{},
+	}
+}
+
+// RoundTrip implements http.RoundTripper. The in-flight slot is held until the
+// response body is closed, so that slow responses count against the limit.
+func (t *hostGuardRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	host := req.URL.Host
+	probe, err := t.acquire(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.next.RoundTrip(req)
+	outcome := outcomeSuccess
+	switch {
+	case err != nil && (stderrs.Is(err, context.Canceled) || stderrs.Is(err, context.DeadlineExceeded) || ctx.Err() != nil):
+		outcome = outcomeCanceled
+	case err != nil || res.StatusCode >= 500:
+		outcome = outcomeFailure
+	}
+	t.record(ctx, host, probe, outcome)
+
+	// The body of a protocol switch must stay writable, so it is not wrapped.
+	if err != nil || res.Body == nil || res.StatusCode == http.StatusSwitchingProtocols {
+		t.release(host)
+		return res, err
+	}
+	res.Body = &hostGuardBody{ReadCloser: res.Body, release: sync.OnceFunc(func() { t.release(host) })}
+	return res, nil
+}
+
+func (t *hostGuardRoundTripper) acquire(ctx context.Context, host string) (probe bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	g, ok := t.hosts[host]
+	if !ok {
+		g = &hostGuard{state: CircuitClosed, windowStart: t.now()}
+		t.hosts[host] = g
+	}
+	g.lastUsed = t.now()
+
+	if t.maxInFlight > 0 && g.inFlight >= t.maxInFlight {
+		hostGuardRejections.WithLabelValues("concurrency_limit").Inc()
+		return false, errors.WithStack(ErrHostConcurrencyLimit)
+	}
+
+	if t.breaker != nil {
+		switch g.state {
+		case CircuitOpen:
+			if t.now().Sub(g.openedAt) < t.breaker.CoolDown {
+				hostGuardRejections.WithLabelValues("circuit_open").Inc()
+				return false, errors.WithStack(ErrCircuitOpen)
+			}
+			t.transition(ctx, host, g, CircuitHalfOpen)
+			fallthrough
+		case CircuitHalfOpen:
+			if g.probeInFlight {
+				hostGuardRejections.WithLabelValues("circuit_open").Inc()
+				return false, errors.WithStack(ErrCircuitOpen)
+			}
+			g.probeInFlight = true
+			probe = true
+		}
+	}
+
+	g.inFlight++
+	return probe, nil
+}
+
+// record updates the circuit breaker with the outcome of a request once its
+// response header has arrived.
+func (t *hostGuardRoundTripper) record(ctx context.Context, host string, probe bool, outcome requestOutcome) {
+	if t.breaker == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	g := t.hosts[host]
+	if probe {
+		g.probeInFlight = false
+		switch outcome {
+		case outcomeFailure:
+			g.openedAt = t.now()
+			t.transition(ctx, host, g, CircuitOpen)
+		case outcomeSuccess:
+			g.windowStart, g.total, g.failed = t.now(), 0, 0
+			t.transition(ctx, host, g, CircuitClosed)
+		}
+		// A canceled probe leaves the circuit half-open for the next one.
+		return
+	}
+	if g.state != CircuitClosed || outcome == outcomeCanceled {
+		return
+	}
+
+	if t.now().Sub(g.windowStart) >= t.breaker.Window {
+		g.windowStart, g.total, g.failed = t.now(), 0, 0
+	}
+	g.total++
+	if outcome == outcomeFailure {
+		g.failed++
+	}
+	if g.total >= t.breaker.MinRequests && float64(g.failed)/float64(g.total) >= t.breaker.FailureRatio {
+		g.openedAt = t.now()
+		t.transition(ctx, host, g, CircuitOpen)
+	}
+}
+
+// release frees the in-flight slot of a request.
+func (t *hostGuardRoundTripper) release(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	g := t.hosts[host]
+	g.inFlight--
+	g.lastUsed = t.now()
+	if t.breaker == nil && g.inFlight == 0 {
+		// Without a circuit breaker, there is no state to keep.
+		delete(t.hosts, host)
+	}
+}
+
+// prune removes the hosts which have not been used for longer than a window
+// and a cool-down, so that the map does not grow with every host ever
+// requested. By then, the counts of a closed circuit would have been reset,
+// and an open circuit would let a probe through. It must be called with the
+// lock held.
+func (t *hostGuardRoundTripper) prune() {
+	if t.breaker == nil {
+		return
+	}
+	now := t.now()
+	if now.Sub(t.lastPrune) < time.Minute {
+		return
+	}
+	t.lastPrune = now
+
+	idle := t.breaker.Window + t.breaker.CoolDown
+	for host, g := range t.hosts {
+		if g.inFlight == 0 && now.Sub(g.lastUsed) >= idle {
+			delete(t.hosts, host)
+		}
+	}
+}
+
+func (t *hostGuardRoundTripper) transition(ctx context.Context, host string, g *hostGuard, to CircuitBreakerState) {
+	if g.state == to {
+		return
+	}
+	trace.SpanFromContext(ctx).AddEvent("CircuitBreakerStateChanged", trace.WithAttributes(
+		attribute.String("host", host),
+		attribute.String("from", string(g.state)),
+		attribute.String("to", string(to)),
+	))
+	circuitBreakerTransitions.WithLabelValues(string(to)).Inc()
+	g.state = to
+}
+
+// hostGuardBody releases the in-flight slot of its request when it is closed.
+type hostGuardBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *hostGuardBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// checkRetryUnlessRejected does not retry requests which were rejected by the
+// host guard or the egress policy, because retrying would defeat their purpose.
+func checkRetryUnlessRejected(next retryablehttp.CheckRetry) retryablehttp.CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if stderrs.Is(err, ErrCircuitOpen) || stderrs.Is(err, ErrHostConcurrencyLimit) || stderrs.Is(err, ErrEgressDenied) {
+			return false, err
+		}
+		return next(ctx, resp, err)
+	}
+}
@@ -33,6 +33,9 @@ func GetClientIPAddressesWithoutInternalIPs(ipAddresses []string) (string, error
 	return res, nil
 }
 
+// ClientIP returns the client IP from well-known proxy headers. It trusts the
+// headers of any caller, so clients can spoof their IP address. Use a
+// ClientIPResolver if the result is used for rate limiting or audit logs.
 func ClientIP(r *http.Request) string {
 	if trueClientIP := r.Header.Get("True-Client-IP"); trueClientIP != "" {
 		return trueClientIP
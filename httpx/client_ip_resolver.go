@@ -0,0 +1,213 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package httpx
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	HeaderForwarded      = "Forwarded"
+	HeaderXForwardedFor  = "X-Forwarded-For"
+	HeaderXRealIP        = "X-Real-IP"
+	HeaderTrueClientIP   = "True-Client-IP"
+	HeaderCfConnectingIP = "Cf-Connecting-IP"
+)
+
+type (
+	// ClientIPResolver determines the IP address of the client which sent a
+	// request. Unlike ClientIP, it only trusts headers set by the configured
+	// proxies, so that clients can not spoof their IP address.
+	ClientIPResolver struct {
+		trustedProxies []netip.Prefix
+		headers        []string
+	}
+
+	clientIPResolverOptions struct {
+		trustedProxies []string
+		headers        []string
+	}
+
+	// ClientIPResolverOption configures a ClientIPResolver.
+	ClientIPResolverOption func(o *clientIPResolverOptions)
+)
+
+// ClientIPResolverWithTrustedProxies sets the CIDRs of the proxies whose
+// headers are trusted, e.g. "10.0.0.0/8". Single IP addresses are accepted as
+// well. Without trusted proxies, the resolver always returns the remote
+// address of the connection.
+func ClientIPResolverWithTrustedProxies(cidrs ...string) ClientIPResolverOption {
+	return func(o *clientIPResolverOptions) {
+		o.trustedProxies = append(o.trustedProxies, cidrs...)
+	}
+}
+
+// ClientIPResolverWithTrustedHeaders sets the headers which are consulted, in
+// order, to determine the client IP, e.g. Cf-Connecting-IP followed by
+// X-Forwarded-For. Defaults to X-Forwarded-For. Only list headers which the
+// trusted proxies overwrite or append to, because clients can send any other
+// header through the proxies unchanged. For example, list Forwarded only if
+// all proxies append to it. Headers other than Forwarded and X-Forwarded-For
+// must contain a single IP address, e.g. X-Real-IP or Cf-Connecting-IP.
+func ClientIPResolverWithTrustedHeaders(headers ...string) ClientIPResolverOption {
+	return func(o *clientIPResolverOptions) {
+		o.headers = headers
+	}
+}
+
+// NewClientIPResolver returns a new ClientIPResolver.
+func NewClientIPResolver(opts ...ClientIPResolverOption) (*ClientIPResolver, error) {
+	o := &clientIPResolverOptions{
+		headers: []string{HeaderXForwardedFor},
+	}
+	for _, f := range opts {
+		f(o)
+	}
+
+	r := &ClientIPResolver{}
+	for _, cidr := range o.trustedProxies {
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid trusted proxy %q", cidr)
+			}
+			r.trustedProxies = append(r.trustedProxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid trusted proxy %q", cidr)
+		}
+		r.trustedProxies = append(r.trustedProxies, prefix.Masked())
+	}
+	for _, h := range o.headers {
+		r.headers = append(r.headers, http.CanonicalHeaderKey(h))
+	}
+	return r, nil
+}
+
+// ClientIP returns the IP address of the client. If the request was received
+// from a trusted proxy, the trusted headers are consulted in order, and the
+// first one which yields an IP address wins. Forwarded and X-Forwarded-For are
+// walked from right to left, skipping trusted proxies, so that the first
+// untrusted hop is returned. Otherwise, the remote address of the connection
+// is returned.
+func (c *ClientIPResolver) ClientIP(r *http.Request) string {
+	remote, ok := remoteAddr(r)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !c.isTrusted(remote) {
+		return remote.String()
+	}
+
+	for _, h := range c.headers {
+		if ip, ok := c.fromHeader(r.Header, h); ok {
+			return ip.String()
+		}
+	}
+	return remote.String()
+}
+
+// ClientGeoLocation returns the geo location headers set by Cloudflare, if
+// the request was received from a trusted proxy. Otherwise, an empty location
+// is returned.
+func (c *ClientIPResolver) ClientGeoLocation(r *http.Request) *GeoLocation {
+	if remote, ok := remoteAddr(r); !ok || !c.isTrusted(remote) {
+		return &GeoLocation{}
+	}
+	return ClientGeoLocation(r)
+}
+
+func (c *ClientIPResolver) isTrusted(ip netip.Addr) bool {
+	for _, p := range c.trustedProxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ClientIPResolver) fromHeader(h http.Header, name string) (netip.Addr, bool) {
+	var hops []string
+	switch name {
+	case HeaderForwarded:
+		hops = parseForwardedFor(h.Values(name))
+	case HeaderXForwardedFor:
+		for _, v := range h.Values(name) {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+	default:
+		return parseIP(h.Get(name))
+	}
+
+	// Walk from the closest hop to the client, skipping trusted proxies.
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, ok := parseIP(hops[i])
+		if !ok {
+			// Unknown or obfuscated hops can not be trusted to report the
+			// hops before them.
+			return netip.Addr{}, false
+		}
+		if i == 0 || !c.isTrusted(ip) {
+			return ip, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// parseForwardedFor returns the for= parameters of the RFC 7239 Forwarded
+// header values, in order.
+func parseForwardedFor(values []string) (hops []string) {
+	for _, v := range values {
+		for _, element := range splitOutsideQuotes(v, ',') {
+			for _, pair := range splitOutsideQuotes(element, ';') {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(value, `"`))
+				}
+			}
+		}
+	}
+	return hops
+}
+
+func splitOutsideQuotes(s string, sep byte) (parts []string) {
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseIP parses an IP address which may have a port and IPv6 brackets, as
+// in "[2001:db8::1]:4711" or "192.0.2.1:80".
+func parseIP(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	ip, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
+
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	return parseIP(r.RemoteAddr)
+}
@@ -0,0 +1,425 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package httpx
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+	"github.com/urfave/negroni"
+)
+
+const (
+	EncodingGzip     = "gzip"
+	EncodingBrotli   = "br"
+	EncodingZstd     = "zstd"
+	EncodingIdentity = "identity"
+)
+
+// DefaultCompressibleContentTypes are the content types compressed by
+// CompressionResponseWriter unless configured otherwise.
+var DefaultCompressibleContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"application/*+xml",
+	"application/x-www-form-urlencoded",
+	"image/svg+xml",
+}
+
+type (
+	// CompressionResponseWriter is a negroni middleware which compresses
+	// responses with gzip, Brotli, or Zstandard, depending on the
+	// Accept-Encoding header of the request.
+	CompressionResponseWriter struct {
+		minSize      int
+		contentTypes []string
+		encodings    []string
+	}
+
+	compressionOptions struct {
+		minSize      int
+		contentTypes []string
+		encodings    []string
+	}
+
+	// CompressionOption configures CompressionResponseWriter.
+	CompressionOption func(o *compressionOptions)
+)
+
+// CompressionWithMinSize sets the body size in bytes below which responses are
+// not compressed. Defaults to 1024.
+func CompressionWithMinSize(n int) CompressionOption {
+	return func(o *compressionOptions) {
+		o.minSize = n
+	}
+}
+
+// CompressionWithContentTypes sets the media types which are compressed, e.g.
+// "application/json". Wildcards are supported for the subtype, e.g. "text/*",
+// and for the subtype prefix, e.g. "application/*+json". Defaults to
+// DefaultCompressibleContentTypes.
+func CompressionWithContentTypes(types ...string) CompressionOption {
+	return func(o *compressionOptions) {
+		o.contentTypes = types
+	}
+}
+
+// CompressionWithEncodings sets the supported encodings in order of the
+// server's preference, which breaks ties between equally weighted encodings
+// of the Accept-Encoding header. Defaults to zstd, br, gzip.
+func CompressionWithEncodings(encodings ...string) CompressionOption {
+	return func(o *compressionOptions) {
+		o.encodings = encodings
+	}
+}
+
+// NewCompressionResponseWriter returns a new CompressionResponseWriter.
+//
+//	n := negroni.New()
+//	n.Use(httpx.NewCompressionResponseWriter())
+//
+// Responses are compressed only if the body is at least the minimum size and
+// its Content-Type is allowed. Responses which already have a Content-Encoding
+// are passed through unchanged. Handlers after the middleware can still use
+// GetResponseMeta, which reports the status code and the uncompressed size.
+// Middlewares before it see the compressed size.
+func NewCompressionResponseWriter(opts ...CompressionOption) *CompressionResponseWriter {
+	o := &compressionOptions{
+		minSize:      1024,
+		contentTypes: DefaultCompressibleContentTypes,
+		encodings:    []string{EncodingZstd, EncodingBrotli, EncodingGzip},
+	}
+	for _, f := range opts {
+		f(o)
+	}
+
+	c := &CompressionResponseWriter{minSize: o.minSize}
+	for _, t := range o.contentTypes {
+		c.contentTypes = append(c.contentTypes, strings.ToLower(t))
+	}
+	for _, e := range o.encodings {
+		if _, ok := encoderPools[strings.ToLower(e)]; ok {
+			c.encodings = append(c.encodings, strings.ToLower(e))
+		}
+	}
+	return c
+}
+
+func (c *CompressionResponseWriter) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := negotiateEncoding(r.Header.Values("Accept-Encoding"), c.encodings)
+	if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+		next(w, r)
+		return
+	}
+
+	cw := &compressResponseWriter{
+		ResponseWriter: w,
+		compressor:     c,
+		encoding:       encoding,
+	}
+	defer cw.close()
+	next(cw, r)
+}
+
+func (c *CompressionResponseWriter) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range c.contentTypes {
+		if matchMediaType(allowed, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchMediaType(pattern, mediaType string) bool {
+	if pattern == mediaType {
+		return true
+	}
+	typ, sub, ok := strings.Cut(mediaType, "/")
+	if !ok {
+		return false
+	}
+	patternType, patternSub, ok := strings.Cut(pattern, "/")
+	if !ok || patternType != typ {
+		return false
+	}
+	if patternSub == "*" {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(patternSub, "*"); ok {
+		return strings.HasSuffix(sub, suffix)
+	}
+	return false
+}
+
+// negotiateEncoding returns the supported encoding with the highest weight in
+// the Accept-Encoding header values, or an empty string if the response should
+// not be compressed. See RFC 9110, Section 12.5.3.
+func negotiateEncoding(accept []string, supported []string) string {
+	if len(accept) == 0 {
+		return ""
+	}
+
+	weights := map[string]float64{}
+	wildcard := -1.0
+	for _, v := range accept {
+		for _, part := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+
+			q := 1.0
+			for _, p := range strings.Split(params, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(p), "=")
+				if ok && strings.EqualFold(key, "q") {
+					if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+						q = parsed
+					}
+				}
+			}
+
+			if name == "*" {
+				wildcard = q
+			} else {
+				weights[name] = q
+			}
+		}
+	}
+
+	var best string
+	bestWeight := 0.0
+	for _, e := range supported {
+		q, ok := weights[e]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestWeight {
+			best, bestWeight = e, q
+		}
+	}
+	return best
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() any {
+		return encoder(gzip.NewWriter(nil))
+	}},
+	EncodingBrotli: {New: func() any {
+		return encoder(brotli.NewWriterLevel(nil, 4))
+	}},
+	EncodingZstd: {New: func() any {
+		e, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+		return encoder(e)
+	}},
+}
+
+var (
+	_ negroni.ResponseWriter = (*compressResponseWriter)(nil)
+	_ http.Hijacker          = (*compressResponseWriter)(nil)
+)
+
+// compressResponseWriter buffers the body until the minimum size is reached,
+// and then decides whether to compress it.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	compressor *CompressionResponseWriter
+	encoding   string
+
+	status      int
+	size        int
+	buf         []byte
+	decided     bool
+	enc         encoder
+	beforeFuncs []func(negroni.ResponseWriter)
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	// Informational responses are sent right away and do not end the header.
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	if !bodyAllowedForStatus(status) {
+		_ = w.decide(false)
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if _, ok := w.Header()["Content-Type"]; !ok && !w.decided {
+		// Sniff the content type like the standard library would, before it
+		// is hidden by the compression.
+		w.Header().Set("Content-Type", http.DetectContentType(append(w.buf, b...)))
+	}
+	w.size += len(b)
+
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.compressor.minSize {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide writes the header, compressed if compress is true and the response
+// is eligible, and flushes the buffered body.
+func (w *compressResponseWriter) decide(compress bool) error {
+	if w.decided {
+		return nil
+	}
+	w.decided = true
+
+	h := w.Header()
+	if compress && h.Get("Content-Encoding") == "" && w.compressor.compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		// Strong validators must change with the representation.
+		if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.enc = encoderPools[w.encoding].Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+
+	for i := len(w.beforeFuncs) - 1; i >= 0; i-- {
+		w.beforeFuncs[i](w)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return errors.WithStack(err)
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return errors.WithStack(err)
+}
+
+func (w *compressResponseWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// Nothing was written, leave it to the server to send the
+			// default response.
+			return
+		}
+		// The body is smaller than the minimum size.
+		_ = w.decide(false)
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		w.enc.Reset(nil)
+		encoderPools[w.encoding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// Flush sends the buffered body, compressing it if it is eligible, even if
+// the minimum size has not been reached.
+func (w *compressResponseWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = w.decide(len(w.buf) > 0)
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	w.decided = true
+	return h.Hijack()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status implements negroni.ResponseWriter.
+func (w *compressResponseWriter) Status() int {
+	return w.status
+}
+
+// Written implements negroni.ResponseWriter.
+func (w *compressResponseWriter) Written() bool {
+	return w.status != 0
+}
+
+// Size implements negroni.ResponseWriter and returns the uncompressed size of
+// the body.
+func (w *compressResponseWriter) Size() int {
+	return w.size
+}
+
+// Before implements negroni.ResponseWriter.
+func (w *compressResponseWriter) Before(f func(negroni.ResponseWriter)) {
+	w.beforeFuncs = append(w.beforeFuncs, f)
+}
+
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
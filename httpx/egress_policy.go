@@ -0,0 +1,251 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package httpx
+
+import (
+	"context"
+	stderrs "errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ory/x/ipx"
+	"github.com/ory/x/logrusx"
+)
+
+// ErrEgressDenied is returned if a request is not permitted by the egress
+// policy. Such requests are not retried.
+var ErrEgressDenied = stderrs.New("destination is not permitted by the egress policy")
+
+// EgressPolicy declares which destinations outgoing requests may reach. It
+// is enforced when connections are dialed, against the IP address the
+// connection is actually made to, so that DNS rebinding can not bypass it.
+//
+// Use it with the resilient client, e.g. for fetcher.Fetcher, the jwksx
+// fetchers, or webhook senders:
+//
+//	client := httpx.NewResilientClient(httpx.ResilientClientWithEgressPolicy(&httpx.EgressPolicy{
+//		AllowedHosts: []string{"*.example.com"},
+//		AllowedPorts: []uint16{443},
+//		MaxRedirects: 3,
+//	}))
+//	f := fetcher.NewFetcher(fetcher.WithClient(client))
+type EgressPolicy struct {
+	// AllowedCIDRs permits IP addresses in these ranges, even if they are
+	// internal. By default, only public IP addresses are permitted.
+	AllowedCIDRs []netip.Prefix
+	// DeniedCIDRs rejects IP addresses in these ranges. If an IP address is
+	// in both an allowed and a denied range, the more specific range wins,
+	// and the denied range wins a tie. Denying 0.0.0.0/0 and ::/0 turns
+	// AllowedCIDRs into an exclusive allow list.
+	DeniedCIDRs []netip.Prefix
+	// AllowedHosts restricts the hostnames requests may be sent to, if set.
+	// A leading "*." matches any subdomain, e.g. "*.example.com" matches
+	// "api.example.com" but not "example.com". IP addresses used as hosts
+	// must be listed as well.
+	AllowedHosts []string
+	// AllowedPorts restricts the ports requests may be sent to, if set.
+	AllowedPorts []uint16
+	// MaxRedirects is the number of redirects which are followed. Every hop
+	// is checked against the policy again. Zero disables redirects.
+	MaxRedirects int
+}
+
+// ResilientClientWithEgressPolicy enforces the egress policy for all requests
+// of the client. It replaces ResilientClientDisallowInternalIPs and its
+// exceptions. Requests are not sent through a proxy, because the policy can
+// only be enforced for direct connections.
+func ResilientClientWithEgressPolicy(p *EgressPolicy) ResilientOptions {
+	return func(o *resilientOptions) { o.egressPolicy = p }
+}
+
+// Transport returns a transport which enforces the policy and logs its
+// decisions to the logger, which may be nil.
+func (p *EgressPolicy) Transport(l *logrusx.Logger) http.RoundTripper {
+	g := &egressGuard{policy: p, l: l}
+	g.dialer = &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   g.control,
+	}
+
+	t := ssrfTransport(g.dial)
+	t.Proxy = nil
+	return OTELTraceTransport(t)
+}
+
+// CheckRedirect implements the http.Client CheckRedirect hook. It stops after
+// MaxRedirects and checks the host and port of every hop.
+func (p *EgressPolicy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > p.MaxRedirects {
+		return errors.Wrapf(ErrEgressDenied, "stopped after %d redirects", p.MaxRedirects)
+	}
+	port := req.URL.Port()
+	if port == "" {
+		port = "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return p.checkHostPort(req.URL.Hostname(), port)
+}
+
+func (p *EgressPolicy) checkHostPort(host, port string) error {
+	if len(p.AllowedHosts) > 0 && !slices.ContainsFunc(p.AllowedHosts, func(pattern string) bool {
+		return matchHost(pattern, host)
+	}) {
+		return errors.Wrapf(ErrEgressDenied, "host %s is not allowed", host)
+	}
+
+	if len(p.AllowedPorts) > 0 {
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || !slices.Contains(p.AllowedPorts, uint16(n)) {
+			return errors.Wrapf(ErrEgressDenied, "port %s is not allowed", port)
+		}
+	}
+	return nil
+}
+
+func (p *EgressPolicy) checkIP(ip netip.Addr) error {
+	ip = ip.Unmap()
+	allowedBits, deniedBits := -1, -1
+	for _, prefix := range p.AllowedCIDRs {
+		if prefix.Contains(ip) {
+			allowedBits = max(allowedBits, prefix.Bits())
+		}
+	}
+	for _, prefix := range p.DeniedCIDRs {
+		if prefix.Contains(ip) {
+			deniedBits = max(deniedBits, prefix.Bits())
+		}
+	}
+
+	switch {
+	case deniedBits >= 0 && deniedBits >= allowedBits:
+		return errors.Wrapf(ErrEgressDenied, "ip %s is in a denied range", ip)
+	case allowedBits >= 0:
+		return nil
+	case !ipx.IsPublic(ip):
+		return errors.Wrapf(ErrEgressDenied, "ip %s is not a public address", ip)
+	}
+	return nil
+}
+
+// matchHost matches the hostname against the pattern, which is either a
+// hostname or a wildcard of the form "*.example.com".
+func matchHost(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return pattern == host
+}
+
+// egressGuard dials connections permitted by the policy.
+type egressGuard struct {
+	policy *EgressPolicy
+	dialer *net.Dialer
+	l      *logrusx.Logger
+}
+
+// dial resolves the host itself and dials the first permitted IP address
+// directly, so that the checked address is the one used for the lifetime of
+// the connection.
+func (g *egressGuard) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := g.policy.checkHostPort(host, port); err != nil {
+		g.deny(ctx, host, nil, err)
+		return nil, err
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var ips []netip.Addr
+	if ip, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		ips = []netip.Addr{ip}
+	} else {
+		ipNetwork := "ip"
+		switch network {
+		case "tcp4":
+			ipNetwork = "ip4"
+		case "tcp6":
+			ipNetwork = "ip6"
+		}
+		ips, err = net.DefaultResolver.LookupNetIP(ctx, ipNetwork, host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lastErr error
+	for _, ip := range ips {
+		ip = ip.Unmap()
+		if err := g.policy.checkIP(ip); err != nil {
+			g.deny(ctx, host, &ip, err)
+			lastErr = err
+			continue
+		}
+
+		conn, err := g.dialer.DialContext(ctx, network, netip.AddrPortFrom(ip, uint16(portNum)).String())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		g.allow(ctx, host, ip)
+		return conn, nil
+	}
+	if lastErr == nil {
+		lastErr = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return nil, lastErr
+}
+
+// control checks the address of the socket once more before connecting.
+func (g *egressGuard) control(_, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errors.Wrapf(ErrEgressDenied, "unable to parse address %s", address)
+	}
+	return g.policy.checkIP(addr.Addr())
+}
+
+func (g *egressGuard) allow(ctx context.Context, host string, ip netip.Addr) {
+	if g.l != nil {
+		g.l.WithContext(ctx).
+			WithField("host", host).
+			WithField("ip", ip.String()).
+			Debug("Egress policy permitted the connection.")
+	}
+}
+
+func (g *egressGuard) deny(ctx context.Context, host string, ip *netip.Addr, err error) {
+	attrs := []attribute.KeyValue{attribute.String("host", host), attribute.String("reason", err.Error())}
+	if ip != nil {
+		attrs = append(attrs, attribute.String("ip", ip.String()))
+	}
+	trace.SpanFromContext(ctx).AddEvent("EgressPolicyDenied", trace.WithAttributes(attrs...))
+
+	if g.l != nil {
+		l := g.l.WithContext(ctx).WithField("host", host).WithError(err)
+		if ip != nil {
+			l = l.WithField("ip", ip.String())
+		}
+		l.Warn("Egress policy denied the connection.")
+	}
+}
@@ -9,10 +9,22 @@ import (
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/klauspost/compress/zstd"
 )
 
+// DefaultMaxDecompressedSize is the default maximum size in bytes of a
+// decompressed request body.
+const DefaultMaxDecompressedSize int64 = 32 << 20
+
 type CompressionRequestReader struct {
 	ErrHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	// MaxDecompressedSize limits the size in bytes of decompressed request
+	// bodies, to protect against decompression bombs. Reading beyond the
+	// limit fails with an error. Zero means DefaultMaxDecompressedSize, a
+	// negative value disables the limit.
+	MaxDecompressedSize int64
 }
 
 func defaultCompressionErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
@@ -30,21 +42,48 @@ func NewCompressionRequestReader(eh func(w http.ResponseWriter, r *http.Request,
 }
 
 func (c *CompressionRequestReader) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	for _, enc := range strings.Split(r.Header.Get("Content-Encoding"), ",") {
-		switch enc = strings.TrimSpace(enc); enc {
-		case "gzip":
+	limit := c.MaxDecompressedSize
+	if limit == 0 {
+		limit = DefaultMaxDecompressedSize
+	}
+
+	// Encodings are listed in the order in which they were applied, so they
+	// are removed in reverse.
+	encodings := strings.Split(r.Header.Get("Content-Encoding"), ",")
+	decoded := false
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch enc := strings.ToLower(strings.TrimSpace(encodings[i])); enc {
+		case EncodingGzip:
 			reader, err := gzip.NewReader(r.Body)
 			if err != nil {
 				c.ErrHandler(w, r, err)
 				return
 			}
 			r.Body = io.NopCloser(reader)
-		case "identity", "":
+			decoded = true
+		case EncodingZstd:
+			opts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+			if limit > 0 {
+				opts = append(opts, zstd.WithDecoderMaxMemory(uint64(limit)))
+			}
+			reader, err := zstd.NewReader(r.Body, opts...)
+			if err != nil {
+				c.ErrHandler(w, r, err)
+				return
+			}
+			r.Body = reader.IOReadCloser()
+			decoded = true
+		case EncodingIdentity, "":
 			// nothing to do
 		default:
 			c.ErrHandler(w, r, fmt.Errorf("%s content encoding not supported", enc))
+			return
 		}
 	}
 
+	if decoded && limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
 	next(w, r)
 }
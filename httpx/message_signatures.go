@@ -0,0 +1,708 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package httpx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/pkg/errors"
+)
+
+// The signature algorithms of RFC 9421, Section 3.3.
+const (
+	SignatureAlgorithmRSAPSSSHA512    = "rsa-pss-sha512"
+	SignatureAlgorithmRSAv15SHA256    = "rsa-v1_5-sha256"
+	SignatureAlgorithmHMACSHA256      = "hmac-sha256"
+	SignatureAlgorithmECDSAP256SHA256 = "ecdsa-p256-sha256"
+	SignatureAlgorithmECDSAP384SHA384 = "ecdsa-p384-sha384"
+	SignatureAlgorithmEd25519         = "ed25519"
+)
+
+const messageSignatureLabel = "sig1"
+
+type (
+	// MessageSigner signs requests with RFC 9421 HTTP message signatures. The
+	// signature always covers the method, the target URI, the
+	// Content-Digest of the body, if any, and the Content-Type, if set.
+	MessageSigner struct {
+		key     *jose.JSONWebKey
+		alg     string
+		headers []string
+		now     func() time.Time
+	}
+
+	messageSignerOptions struct {
+		headers []string
+	}
+
+	// MessageSignerOption configures MessageSigner.
+	MessageSignerOption func(o *messageSignerOptions)
+
+	// MessageVerifier verifies requests signed with RFC 9421 HTTP message
+	// signatures, e.g. by MessageSigner.
+	MessageVerifier struct {
+		keys *jose.JSONWebKeySet
+	}
+)
+
+// MessageSignerWithHeaders adds the given header fields to the components
+// covered by the signature. Requests which lack one of the headers can not be
+// signed.
+func MessageSignerWithHeaders(headers ...string) MessageSignerOption {
+	return func(o *messageSignerOptions) {
+		o.headers = append(o.headers, headers...)
+	}
+}
+
+// NewMessageSigner returns a signer which signs requests with the private or
+// symmetric key, e.g. one loaded with josex.LoadJSONWebKey. The key ID is sent
+// as the keyid parameter. The algorithm is derived from the key: RSA keys use
+// rsa-v1_5-sha256 if the key's algorithm is RS256, and rsa-pss-sha512 if it is
+// PS512 or not set. EC keys use
+// ecdsa-p256-sha256 or ecdsa-p384-sha384, Ed25519 keys use ed25519, and
+// symmetric keys use hmac-sha256.
+func NewMessageSigner(key *jose.JSONWebKey, opts ...MessageSignerOption) (*MessageSigner, error) {
+	o := &messageSignerOptions{}
+	for _, f := range opts {
+		f(o)
+	}
+
+	alg, err := messageSignatureAlgorithm(key, true)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MessageSigner{key: key, alg: alg, now: time.Now}
+	for _, h := range o.headers {
+		s.headers = append(s.headers, strings.ToLower(h))
+	}
+	return s, nil
+}
+
+// SignRequest implements RequestSigner.
+func (s *MessageSigner) SignRequest(req *http.Request, body []byte) error {
+	components := []string{"@method", "@target-uri"}
+	if len(body) > 0 {
+		req.Header.Set(HeaderContentDigest, contentDigest(body))
+		components = append(components, "content-digest")
+	}
+	if req.Header.Get("Content-Type") != "" {
+		components = append(components, "content-type")
+	}
+	for _, h := range s.headers {
+		if !slices.Contains(components, h) {
+			components = append(components, h)
+		}
+	}
+
+	params := []sfParam{
+		{key: "created", value: sfInteger(s.now().Unix())},
+		{key: "keyid", value: sfString(s.key.KeyID)},
+		{key: "alg", value: sfString(s.alg)},
+		{key: "nonce", value: sfString(rand.Text())},
+	}
+	base, signatureParams, err := signatureBase(req, outgoingTargetURI(req), components, params)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signMessage(s.alg, s.key.Key, base)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(HeaderSignatureInput, messageSignatureLabel+"="+signatureParams)
+	req.Header.Set(HeaderSignature, messageSignatureLabel+"=:"+base64.StdEncoding.EncodeToString(signature)+":")
+	return nil
+}
+
+// NewMessageVerifier returns a verifier which accepts signatures made with
+// any of the keys, which are looked up by the keyid parameter. Private keys
+// are reduced to their public keys. Each key only accepts the algorithm
+// NewMessageSigner would use with it, so RSA keys must set their algorithm to
+// RS256 to accept rsa-v1_5-sha256 signatures.
+func NewMessageVerifier(keys *jose.JSONWebKeySet) *MessageVerifier {
+	return &MessageVerifier{keys: keys}
+}
+
+// VerifyRequest implements RequestVerifier. The first signature made with a
+// known key is verified. It must cover the method, the target URI, and the
+// Content-Digest if the request has a body, and it must have the created and
+// nonce parameters.
+func (v *MessageVerifier) VerifyRequest(r *http.Request, body []byte) (*VerifiedSignature, error) {
+	if r.Header.Get(HeaderSignatureInput) == "" || r.Header.Get(HeaderSignature) == "" {
+		return nil, errors.WithStack(ErrMissingSignature)
+	}
+
+	inputs, err := parseSFDictionary(strings.Join(r.Header.Values(HeaderSignatureInput), ", "))
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidSignature, "malformed Signature-Input header")
+	}
+	signatures, err := parseSFDictionary(strings.Join(r.Header.Values(HeaderSignature), ", "))
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidSignature, "malformed Signature header")
+	}
+
+	for _, input := range inputs {
+		keyID, ok := input.param("keyid").(sfString)
+		if !ok || input.inner == nil {
+			continue
+		}
+		keys := v.keys.Key(string(keyID))
+		if len(keys) == 0 {
+			continue
+		}
+
+		signature := slices.IndexFunc(signatures, func(m sfMember) bool { return m.key == input.key })
+		if signature < 0 {
+			return nil, errors.Wrapf(ErrInvalidSignature, "signature %s is missing", input.key)
+		}
+		sig, ok := signatures[signature].item.(sfByteSequence)
+		if !ok {
+			return nil, errors.Wrapf(ErrInvalidSignature, "signature %s is malformed", input.key)
+		}
+		return v.verify(r, body, input, &keys[0], sig)
+	}
+	return nil, errors.Wrap(ErrInvalidSignature, "no signature was made with a known key")
+}
+
+func (v *MessageVerifier) verify(r *http.Request, body []byte, input sfMember, key *jose.JSONWebKey, signature []byte) (*VerifiedSignature, error) {
+	components := make([]string, 0, len(input.inner))
+	for _, c := range input.inner {
+		components = append(components, string(c))
+	}
+	for _, required := range []string{"@method", "@target-uri"} {
+		if !slices.Contains(components, required) {
+			return nil, errors.Wrapf(ErrInvalidSignature, "signature does not cover %s", required)
+		}
+	}
+	if len(body) > 0 {
+		if !slices.Contains(components, "content-digest") {
+			return nil, errors.Wrap(ErrInvalidSignature, "signature does not cover content-digest")
+		}
+		if err := verifyContentDigest(r.Header.Values(HeaderContentDigest), body); err != nil {
+			return nil, err
+		}
+	}
+
+	created, ok := input.param("created").(sfInteger)
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidSignature, "signature parameter created is missing")
+	}
+	nonce, ok := input.param("nonce").(sfString)
+	if !ok || nonce == "" {
+		return nil, errors.Wrap(ErrInvalidSignature, "signature parameter nonce is missing")
+	}
+
+	alg, err := messageSignatureAlgorithm(key, false)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidSignature, err.Error())
+	}
+	if requested, ok := input.param("alg").(sfString); ok && string(requested) != alg {
+		return nil, errors.Wrapf(ErrInvalidSignature, "algorithm %s does not match the key", requested)
+	}
+
+	base, _, err := signatureBase(r, incomingTargetURI(r), components, input.params)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidSignature, err.Error())
+	}
+	verificationKey := key.Key
+	if _, symmetric := key.Key.([]byte); !symmetric {
+		verificationKey = key.Public().Key
+	}
+	if err := verifyMessage(alg, verificationKey, base, signature); err != nil {
+		return nil, err
+	}
+
+	verified := &VerifiedSignature{
+		KeyID:   key.KeyID,
+		Nonce:   string(nonce),
+		Created: time.Unix(int64(created), 0),
+	}
+	if expires, ok := input.param("expires").(sfInteger); ok {
+		verified.Expires = time.Unix(int64(expires), 0)
+	}
+	return verified, nil
+}
+
+func verifyContentDigest(values []string, body []byte) error {
+	digests, err := parseSFDictionary(strings.Join(values, ", "))
+	if err != nil {
+		return errors.Wrap(ErrInvalidSignature, "malformed Content-Digest header")
+	}
+	i := slices.IndexFunc(digests, func(m sfMember) bool { return m.key == "sha-256" })
+	if i < 0 {
+		return errors.Wrap(ErrInvalidSignature, "Content-Digest header has no sha-256 digest")
+	}
+	if digests[i].serializeItem() != strings.TrimPrefix(contentDigest(body), "sha-256=") {
+		return errors.Wrap(ErrInvalidSignature, "Content-Digest does not match the body")
+	}
+	return nil
+}
+
+// signatureBase returns the signature base of RFC 9421, Section 2.5, and the
+// serialized signature parameters.
+func signatureBase(r *http.Request, targetURI string, components []string, params []sfParam) (base []byte, signatureParams string, err error) {
+	target, err := url.Parse(targetURI)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+
+	var b strings.Builder
+	for _, c := range components {
+		var value string
+		switch c {
+		case "@method":
+			value = r.Method
+		case "@target-uri":
+			value = targetURI
+		case "@authority":
+			value = strings.ToLower(target.Host)
+		case "@scheme":
+			value = strings.ToLower(target.Scheme)
+		case "@path":
+			value = target.EscapedPath()
+			if value == "" {
+				value = "/"
+			}
+		case "@query":
+			value = "?" + target.RawQuery
+		default:
+			if strings.HasPrefix(c, "@") {
+				return nil, "", errors.Errorf("unsupported derived component %s", c)
+			}
+			values := r.Header.Values(c)
+			if len(values) == 0 {
+				return nil, "", errors.Errorf("header %s is missing", c)
+			}
+			for i := range values {
+				values[i] = strings.TrimSpace(values[i])
+			}
+			value = strings.Join(values, ", ")
+		}
+		b.WriteString(sfString(c).serialize() + ": " + value + "\n")
+	}
+
+	signatureParams = sfMember{inner: toSFStrings(components), params: params}.serializeItem()
+	b.WriteString(`"@signature-params": ` + signatureParams)
+	return []byte(b.String()), signatureParams, nil
+}
+
+// messageSignatureAlgorithm returns the only algorithm which may be used with
+// the key. Signatures are never verified with another algorithm, because the
+// signer could otherwise pick the weakest one the key supports.
+func messageSignatureAlgorithm(key *jose.JSONWebKey, private bool) (string, error) {
+	if key == nil {
+		return "", errors.New("signing key is missing")
+	}
+
+	switch k := key.Key.(type) {
+	case []byte:
+		return SignatureAlgorithmHMACSHA256, nil
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		if private {
+			if _, ok := k.(*rsa.PrivateKey); !ok {
+				break
+			}
+		}
+		switch key.Algorithm {
+		case string(jose.RS256):
+			return SignatureAlgorithmRSAv15SHA256, nil
+		case "", string(jose.PS512):
+			return SignatureAlgorithmRSAPSSSHA512, nil
+		}
+		return "", errors.Errorf("unsupported algorithm %s for RSA keys", key.Algorithm)
+	case *ecdsa.PrivateKey, *ecdsa.PublicKey:
+		var curve elliptic.Curve
+		switch k := k.(type) {
+		case *ecdsa.PrivateKey:
+			curve = k.Curve
+		case *ecdsa.PublicKey:
+			if private {
+				return "", errors.New("signing requires a private key")
+			}
+			curve = k.Curve
+		}
+		switch curve {
+		case elliptic.P256():
+			return SignatureAlgorithmECDSAP256SHA256, nil
+		case elliptic.P384():
+			return SignatureAlgorithmECDSAP384SHA384, nil
+		}
+		return "", errors.Errorf("unsupported curve %s", curve.Params().Name)
+	case ed25519.PrivateKey:
+		return SignatureAlgorithmEd25519, nil
+	case ed25519.PublicKey:
+		if !private {
+			return SignatureAlgorithmEd25519, nil
+		}
+	default:
+		return "", errors.Errorf("unsupported key type %T", key.Key)
+	}
+	return "", errors.New("signing requires a private key")
+}
+
+func signMessage(alg string, key any, base []byte) ([]byte, error) {
+	switch alg {
+	case SignatureAlgorithmHMACSHA256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		_, _ = mac.Write(base)
+		return mac.Sum(nil), nil
+	case SignatureAlgorithmRSAPSSSHA512:
+		digest := sha512.Sum512(base)
+		sig, err := rsa.SignPSS(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA512, digest[:], &rsa.PSSOptions{SaltLength: 64})
+		return sig, errors.WithStack(err)
+	case SignatureAlgorithmRSAv15SHA256:
+		digest := sha256.Sum256(base)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		return sig, errors.WithStack(err)
+	case SignatureAlgorithmECDSAP256SHA256, SignatureAlgorithmECDSAP384SHA384:
+		k := key.(*ecdsa.PrivateKey)
+		r, s, err := ecdsa.Sign(rand.Reader, k, ecdsaDigest(alg, base))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// RFC 9421 uses the fixed-size concatenation of r and s.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+		return sig, nil
+	case SignatureAlgorithmEd25519:
+		return ed25519.Sign(key.(ed25519.PrivateKey), base), nil
+	}
+	return nil, errors.Errorf("unsupported signature algorithm %s", alg)
+}
+
+func verifyMessage(alg string, key any, base, signature []byte) error {
+	var valid bool
+	switch alg {
+	case SignatureAlgorithmHMACSHA256:
+		expected, _ := signMessage(alg, key, base)
+		valid = hmac.Equal(expected, signature)
+	case SignatureAlgorithmRSAPSSSHA512:
+		digest := sha512.Sum512(base)
+		valid = rsa.VerifyPSS(key.(*rsa.PublicKey), crypto.SHA512, digest[:], signature, &rsa.PSSOptions{SaltLength: 64}) == nil
+	case SignatureAlgorithmRSAv15SHA256:
+		digest := sha256.Sum256(base)
+		valid = rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case SignatureAlgorithmECDSAP256SHA256, SignatureAlgorithmECDSAP384SHA384:
+		k := key.(*ecdsa.PublicKey)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) == 2*size {
+			r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(k, ecdsaDigest(alg, base), r, s)
+		}
+	case SignatureAlgorithmEd25519:
+		valid = ed25519.Verify(key.(ed25519.PublicKey), base, signature)
+	}
+	if !valid {
+		return errors.WithStack(ErrInvalidSignature)
+	}
+	return nil
+}
+
+func ecdsaDigest(alg string, base []byte) []byte {
+	if alg == SignatureAlgorithmECDSAP384SHA384 {
+		digest := sha512.Sum384(base)
+		return digest[:]
+	}
+	digest := sha256.Sum256(base)
+	return digest[:]
+}
+
+// The following is a parser and serializer for the subset of RFC 8941
+// structured fields used by HTTP message signatures: dictionaries whose
+// members are byte sequences or inner lists of strings, with parameters.
+
+type (
+	sfString       string
+	sfInteger      int64
+	sfToken        string
+	sfByteSequence []byte
+	sfBoolean      bool
+
+	sfParam struct {
+		key   string
+		value any
+	}
+
+	sfMember struct {
+		key    string
+		item   any
+		inner  []sfString
+		params []sfParam
+	}
+)
+
+func toSFStrings(s []string) []sfString {
+	out := make([]sfString, len(s))
+	for i := range s {
+		out[i] = sfString(s[i])
+	}
+	return out
+}
+
+func (s sfString) serialize() string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(string(s)) + `"`
+}
+
+func (m sfMember) param(key string) any {
+	for _, p := range m.params {
+		if p.key == key {
+			return p.value
+		}
+	}
+	return nil
+}
+
+func (m sfMember) serializeItem() string {
+	var b strings.Builder
+	if m.inner != nil {
+		b.WriteString("(")
+		for i, s := range m.inner {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			b.WriteString(s.serialize())
+		}
+		b.WriteString(")")
+	} else {
+		b.WriteString(serializeSFBareItem(m.item))
+	}
+	for _, p := range m.params {
+		b.WriteString(";" + p.key)
+		if v, ok := p.value.(sfBoolean); !ok || !bool(v) {
+			b.WriteString("=" + serializeSFBareItem(p.value))
+		}
+	}
+	return b.String()
+}
+
+func serializeSFBareItem(v any) string {
+	switch v := v.(type) {
+	case sfString:
+		return v.serialize()
+	case sfInteger:
+		return strconv.FormatInt(int64(v), 10)
+	case sfToken:
+		return string(v)
+	case sfByteSequence:
+		return ":" + base64.StdEncoding.EncodeToString(v) + ":"
+	case sfBoolean:
+		if v {
+			return "?1"
+		}
+		return "?0"
+	}
+	return ""
+}
+
+type sfParser struct {
+	s string
+	i int
+}
+
+func parseSFDictionary(s string) ([]sfMember, error) {
+	p := &sfParser{s: s}
+	var members []sfMember
+	p.skipSpace()
+	for p.i < len(p.s) {
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+
+		m := sfMember{key: key, item: sfBoolean(true)}
+		if p.consume('=') {
+			if p.peek() == '(' {
+				if m.inner, err = p.innerList(); err != nil {
+					return nil, err
+				}
+				m.item = nil
+			} else if m.item, err = p.bareItem(); err != nil {
+				return nil, err
+			}
+		}
+		if m.params, err = p.params(); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+
+		p.skipSpace()
+		if p.i == len(p.s) {
+			break
+		}
+		if !p.consume(',') {
+			return nil, errors.Errorf("expected a comma at position %d", p.i)
+		}
+		p.skipSpace()
+	}
+	return members, nil
+}
+
+func (p *sfParser) peek() byte {
+	if p.i < len(p.s) {
+		return p.s[p.i]
+	}
+	return 0
+}
+
+func (p *sfParser) consume(c byte) bool {
+	if p.peek() == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *sfParser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+func (p *sfParser) key() (string, error) {
+	start := p.i
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' && c != '-' && c != '.' && c != '*' {
+			break
+		}
+		p.i++
+	}
+	if start == p.i {
+		return "", errors.Errorf("expected a key at position %d", p.i)
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *sfParser) innerList() ([]sfString, error) {
+	p.consume('(')
+	items := []sfString{}
+	for {
+		for p.consume(' ') {
+		}
+		if p.consume(')') {
+			return items, nil
+		}
+		item, err := p.bareItem()
+		if err != nil {
+			return nil, err
+		}
+		s, ok := item.(sfString)
+		if !ok {
+			return nil, errors.New("inner list items must be strings")
+		}
+		if p.peek() == ';' {
+			return nil, errors.New("component parameters are not supported")
+		}
+		items = append(items, s)
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, errors.Errorf("unexpected character at position %d", p.i)
+		}
+	}
+}
+
+func (p *sfParser) params() ([]sfParam, error) {
+	var params []sfParam
+	for p.consume(';') {
+		p.skipSpace()
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		var value any = sfBoolean(true)
+		if p.consume('=') {
+			if value, err = p.bareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params = append(params, sfParam{key: key, value: value})
+	}
+	return params, nil
+}
+
+func (p *sfParser) bareItem() (any, error) {
+	switch c := p.peek(); {
+	case c == '"':
+		p.i++
+		var b strings.Builder
+		for p.i < len(p.s) {
+			c := p.s[p.i]
+			p.i++
+			switch {
+			case c == '\\':
+				if p.i == len(p.s) || (p.s[p.i] != '"' && p.s[p.i] != '\\') {
+					return nil, errors.New("invalid escape in string")
+				}
+				b.WriteByte(p.s[p.i])
+				p.i++
+			case c == '"':
+				return sfString(b.String()), nil
+			case c < 0x20 || c > 0x7e:
+				return nil, errors.New("invalid character in string")
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return nil, errors.New("unterminated string")
+	case c == ':':
+		p.i++
+		end := strings.IndexByte(p.s[p.i:], ':')
+		if end < 0 {
+			return nil, errors.New("unterminated byte sequence")
+		}
+		b, err := base64.StdEncoding.DecodeString(p.s[p.i : p.i+end])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		p.i += end + 1
+		return sfByteSequence(b), nil
+	case c == '?':
+		p.i++
+		switch {
+		case p.consume('1'):
+			return sfBoolean(true), nil
+		case p.consume('0'):
+			return sfBoolean(false), nil
+		}
+		return nil, errors.New("invalid boolean")
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.i
+		p.i++
+		for p.i < len(p.s) && p.s[p.i] >= '0' && p.s[p.i] <= '9' {
+			p.i++
+		}
+		n, err := strconv.ParseInt(p.s[start:p.i], 10, 64)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return sfInteger(n), nil
+	case c == '*' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		start := p.i
+		for p.i < len(p.s) && !strings.ContainsRune(" \t,;()=\"", rune(p.s[p.i])) {
+			p.i++
+		}
+		return sfToken(p.s[start:p.i]), nil
+	}
+	return nil, errors.Errorf("unexpected character at position %d", p.i)
+}
@@ -18,13 +18,18 @@ import (
 )
 
 type resilientOptions struct {
-	c                    *http.Client
-	l                    interface{}
-	retryWaitMin         time.Duration
-	retryWaitMax         time.Duration
-	retryMax             int
-	noInternalIPs        bool
-	internalIPExceptions []string
+	c                     *http.Client
+	l                     interface{}
+	retryWaitMin          time.Duration
+	retryWaitMax          time.Duration
+	retryMax              int
+	noInternalIPs         bool
+	internalIPExceptions  []string
+	circuitBreaker        *CircuitBreakerConfig
+	maxInFlightPerHost    int
+	retryBudget           *RetryBudget
+	idempotentRetriesOnly bool
+	egressPolicy          *EgressPolicy
 }
 
 func newResilientOptions() *resilientOptions {
@@ -50,7 +55,8 @@ func ResilientClientWithMinRetryWait(retryWaitMin time.Duration) ResilientOption
 	return func(o *resilientOptions) { o.retryWaitMin = retryWaitMin }
 }
 
-// ResilientClientWithMaxRetryWait sets the maximum wait time for a retry.
+// ResilientClientWithMaxRetryWait sets the maximum wait time for a retry. It
+// also caps the wait requested by a Retry-After header.
 func ResilientClientWithMaxRetryWait(retryWaitMax time.Duration) ResilientOptions {
 	return func(o *resilientOptions) { o.retryWaitMax = retryWaitMax }
 }
@@ -92,7 +98,13 @@ func NewResilientClient(opts ...ResilientOptions) *retryablehttp.Client {
 		f(o)
 	}
 
-	if o.noInternalIPs {
+	if o.egressPolicy != nil {
+		l, _ := o.l.(*logrusx.Logger)
+		o.c.Transport = o.egressPolicy.Transport(l)
+		if o.c.CheckRedirect == nil {
+			o.c.CheckRedirect = o.egressPolicy.CheckRedirect
+		}
+	} else if o.noInternalIPs {
 		o.c.Transport = &noInternalIPRoundTripper{
 			onWhitelist:          allowInternalAllowIPv6,
 			notOnWhitelist:       prohibitInternalAllowIPv6,
@@ -101,6 +113,18 @@ func NewResilientClient(opts ...ResilientOptions) *retryablehttp.Client {
 	} else {
 		o.c.Transport = allowInternalAllowIPv6
 	}
+	if o.circuitBreaker != nil || o.maxInFlightPerHost > 0 {
+		o.c.Transport = newHostGuardRoundTripper(o.c.Transport, o.circuitBreaker, o.maxInFlightPerHost)
+	}
+
+	checkRetry := retryablehttp.DefaultRetryPolicy
+	if o.idempotentRetriesOnly {
+		o.c.Transport = &idempotencyRoundTripper{next: o.c.Transport}
+		checkRetry = checkRetryIdempotent(checkRetry)
+	}
+	if o.retryBudget != nil {
+		checkRetry = o.retryBudget.checkRetry(checkRetry)
+	}
 
 	cl := retryablehttp.NewClient()
 	cl.HTTPClient = o.c
@@ -108,8 +132,8 @@ func NewResilientClient(opts ...ResilientOptions) *retryablehttp.Client {
 	cl.RetryWaitMin = o.retryWaitMin
 	cl.RetryWaitMax = o.retryWaitMax
 	cl.RetryMax = o.retryMax
-	cl.CheckRetry = retryablehttp.DefaultRetryPolicy
-	cl.Backoff = retryablehttp.DefaultBackoff
+	cl.CheckRetry = checkRetryUnlessRejected(checkRetry)
+	cl.Backoff = cappedBackoff
 	return cl
 }
 
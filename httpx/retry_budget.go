@@ -0,0 +1,126 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package httpx
+
+import (
+	"context"
+	stderrs "errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// RetryBudget limits retries to a share of all attempts, so that retries do
+// not amplify an overload of the upstream. It is a token bucket: every attempt
+// deposits ratio tokens, and every retry withdraws one token. A budget may be
+// shared by several clients and is safe for concurrent use.
+type RetryBudget struct {
+	mu     sync.Mutex
+	tokens float64
+	ratio  float64
+	max    float64
+}
+
+// NewRetryBudget returns a budget which allows retries for at most ratio of
+// all attempts, e.g. 0.1 for 10%. The bucket holds at most burst tokens and
+// starts full, so that clients with little traffic can still retry.
+func NewRetryBudget(ratio float64, burst int) *RetryBudget {
+	return &RetryBudget{
+		tokens: float64(burst),
+		ratio:  ratio,
+		max:    float64(burst),
+	}
+}
+
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.max)
+}
+
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *RetryBudget) checkRetry(next retryablehttp.CheckRetry) retryablehttp.CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		b.deposit()
+		retry, err := next(ctx, resp, err)
+		if !retry {
+			return false, err
+		}
+		return b.withdraw(), err
+	}
+}
+
+// ResilientClientWithRetryBudget limits the retries of the client to the given
+// budget.
+func ResilientClientWithRetryBudget(b *RetryBudget) ResilientOptions {
+	return func(o *resilientOptions) { o.retryBudget = b }
+}
+
+// ResilientClientWithIdempotentRetriesOnly retries requests with
+// non-idempotent methods, such as POST and PATCH, only if they carry an
+// Idempotency-Key header.
+func ResilientClientWithIdempotentRetriesOnly() ResilientOptions {
+	return func(o *resilientOptions) { o.idempotentRetriesOnly = true }
+}
+
+// isIdempotent reports whether the request may be sent more than once without
+// additional effects, see RFC 9110, Section 9.2.2.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// nonIdempotentError marks transport errors of non-idempotent requests. The
+// retry policy does not get to see the request on transport errors, so the
+// transport marks them.
+type nonIdempotentError struct{ error }
+
+func (e *nonIdempotentError) Unwrap() error { return e.error }
+
+var _ http.RoundTripper = (*idempotencyRoundTripper)(nil)
+
+type idempotencyRoundTripper struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *idempotencyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil && !isIdempotent(req) {
+		return res, &nonIdempotentError{err}
+	}
+	return res, err
+}
+
+func checkRetryIdempotent(next retryablehttp.CheckRetry) retryablehttp.CheckRetry {
+	return func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if _, ok := stderrs.AsType[*nonIdempotentError](err); ok {
+			return false, err
+		}
+		if resp != nil && resp.Request != nil && !isIdempotent(resp.Request) {
+			return false, err
+		}
+		return next(ctx, resp, err)
+	}
+}
+
+// cappedBackoff honours the Retry-After header of 429 and 503 responses like
+// retryablehttp.DefaultBackoff, but never waits longer than waitMax.
+func cappedBackoff(waitMin, waitMax time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	return min(retryablehttp.DefaultBackoff(waitMin, waitMax, attemptNum, resp), waitMax)
+}
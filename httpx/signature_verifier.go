@@ -0,0 +1,200 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package httpx
+
+import (
+	"bytes"
+	"context"
+	stderrs "errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrSignatureExpired is returned if a request was signed too long ago,
+	// or too far in the future, or its signature has expired.
+	ErrSignatureExpired = stderrs.New("request signature has expired")
+	// ErrSignatureReplayed is returned if a signature was already used.
+	ErrSignatureReplayed = stderrs.New("request signature was already used")
+)
+
+type (
+	// ReplayCache remembers the signatures which were already used.
+	ReplayCache interface {
+		// CheckAndStore stores the key until it expires and reports whether
+		// it was not stored already.
+		CheckAndStore(ctx context.Context, key string, expiresAt time.Time) (bool, error)
+	}
+
+	// RequestSignatureVerifier is a negroni middleware which rejects requests
+	// without a valid signature. Signatures must have been created within the
+	// allowed clock skew, and every signature is accepted only once.
+	RequestSignatureVerifier struct {
+		verifier    RequestVerifier
+		clockSkew   time.Duration
+		replayCache ReplayCache
+		maxBodySize int64
+		errHandler  func(w http.ResponseWriter, r *http.Request, err error)
+		now         func() time.Time
+	}
+
+	requestSignatureVerifierOptions struct {
+		clockSkew   time.Duration
+		replayCache ReplayCache
+		maxBodySize int64
+		errHandler  func(w http.ResponseWriter, r *http.Request, err error)
+	}
+
+	// RequestSignatureVerifierOption configures RequestSignatureVerifier.
+	RequestSignatureVerifierOption func(o *requestSignatureVerifierOptions)
+)
+
+// RequestSignatureVerifierWithClockSkew sets how far the creation time of a
+// signature may be in the past or in the future. Defaults to five minutes.
+func RequestSignatureVerifierWithClockSkew(d time.Duration) RequestSignatureVerifierOption {
+	return func(o *requestSignatureVerifierOptions) {
+		o.clockSkew = d
+	}
+}
+
+// RequestSignatureVerifierWithReplayCache sets the cache of used signatures.
+// Defaults to an in-memory cache, which must be replaced by a shared cache if
+// the service runs more than one instance.
+func RequestSignatureVerifierWithReplayCache(c ReplayCache) RequestSignatureVerifierOption {
+	return func(o *requestSignatureVerifierOptions) {
+		o.replayCache = c
+	}
+}
+
+// RequestSignatureVerifierWithMaxBodySize sets the maximum size in bytes of
+// the request body, which is read into memory to verify it. Defaults to
+// 10 MiB.
+func RequestSignatureVerifierWithMaxBodySize(n int64) RequestSignatureVerifierOption {
+	return func(o *requestSignatureVerifierOptions) {
+		o.maxBodySize = n
+	}
+}
+
+// RequestSignatureVerifierWithErrorHandler sets the handler for rejected
+// requests. By default, they are answered with 401 Unauthorized.
+func RequestSignatureVerifierWithErrorHandler(eh func(w http.ResponseWriter, r *http.Request, err error)) RequestSignatureVerifierOption {
+	return func(o *requestSignatureVerifierOptions) {
+		o.errHandler = eh
+	}
+}
+
+func defaultSignatureErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// NewRequestSignatureVerifier returns a middleware which verifies requests
+// with the given verifier, e.g. a HMACRequestVerifier or a MessageVerifier.
+//
+//	n := negroni.New()
+//	n.Use(httpx.NewRequestSignatureVerifier(httpx.NewMessageVerifier(keys)))
+func NewRequestSignatureVerifier(v RequestVerifier, opts ...RequestSignatureVerifierOption) *RequestSignatureVerifier {
+	o := &requestSignatureVerifierOptions{
+		clockSkew:   5 * time.Minute,
+		maxBodySize: 10 << 20,
+		errHandler:  defaultSignatureErrorHandler,
+	}
+	for _, f := range opts {
+		f(o)
+	}
+	if o.replayCache == nil {
+		o.replayCache = NewInMemoryReplayCache()
+	}
+
+	return &RequestSignatureVerifier{
+		verifier:    v,
+		clockSkew:   o.clockSkew,
+		replayCache: o.replayCache,
+		maxBodySize: o.maxBodySize,
+		errHandler:  o.errHandler,
+		now:         time.Now,
+	}
+}
+
+func (v *RequestSignatureVerifier) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, v.maxBodySize))
+		if err != nil {
+			v.errHandler(w, r, errors.Wrap(err, "unable to read the request body"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	signature, err := v.verifier.VerifyRequest(r, body)
+	if err != nil {
+		v.errHandler(w, r, err)
+		return
+	}
+
+	now := v.now()
+	if signature.Created.Before(now.Add(-v.clockSkew)) || signature.Created.After(now.Add(v.clockSkew)) {
+		v.errHandler(w, r, errors.WithStack(ErrSignatureExpired))
+		return
+	}
+	if !signature.Expires.IsZero() && now.After(signature.Expires.Add(v.clockSkew)) {
+		v.errHandler(w, r, errors.WithStack(ErrSignatureExpired))
+		return
+	}
+
+	// After this time, the signature is rejected as expired anyway.
+	fresh, err := v.replayCache.CheckAndStore(r.Context(), signature.KeyID+"\n"+signature.Nonce, signature.Created.Add(v.clockSkew))
+	if err != nil {
+		v.errHandler(w, r, errors.Wrap(err, "unable to check the request signature for replays"))
+		return
+	}
+	if !fresh {
+		v.errHandler(w, r, errors.WithStack(ErrSignatureReplayed))
+		return
+	}
+
+	next(w, r)
+}
+
+// InMemoryReplayCache is a ReplayCache for a single instance.
+type InMemoryReplayCache struct {
+	mu        sync.Mutex
+	keys      map[string]time.Time
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+var _ ReplayCache = (*InMemoryReplayCache)(nil)
+
+// NewInMemoryReplayCache returns an empty in-memory replay cache.
+func NewInMemoryReplayCache() *InMemoryReplayCache {
+	return &InMemoryReplayCache{keys: map[string]time.Time{}, now: time.Now}
+}
+
+// CheckAndStore implements ReplayCache.
+func (c *InMemoryReplayCache) CheckAndStore(_ context.Context, key string, expiresAt time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.Sub(c.lastPrune) > time.Minute {
+		for k, exp := range c.keys {
+			if now.After(exp) {
+				delete(c.keys, k)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	if exp, ok := c.keys[key]; ok && !now.After(exp) {
+		return false, nil
+	}
+	c.keys[key] = expiresAt
+	return true, nil
+}
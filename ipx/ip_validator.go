@@ -98,6 +98,12 @@ func IsAssociatedIPAllowed(ctx context.Context, ipOrHostnameOrURL string) error
 
 var resolver = &net.Resolver{PreferGo: true}
 
+// IsPublic reports whether the IP address is a publicly routable unicast
+// address, i.e. neither private, loopback, link-local, nor otherwise reserved.
+func IsPublic(ip netip.Addr) bool {
+	return allowed(ip.Unmap())
+}
+
 func allowed(ip netip.Addr) bool {
 	if !ip.IsGlobalUnicast() {
 		return false
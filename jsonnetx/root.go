@@ -5,6 +5,8 @@ package jsonnetx
 
 import (
 	"github.com/spf13/cobra"
+
+	"github.com/ory/x/jsonnetsecure"
 )
 
 const GlobHelp = `Glob patterns supports the following special terms in the patterns:
@@ -34,7 +36,7 @@ const GlobHelp = `Glob patterns supports the following special terms in the patt
 // Deprecated: use NewRootCommand instead.
 var RootCommand = &cobra.Command{
 	Use:   "jsonnet",
-	Short: "Helpers for linting and formatting JSONNet code",
+	Short: "Helpers for linting, formatting, and testing JSONNet code",
 }
 
 // RegisterCommandRecursive adds all jsonnet helpers to the RootCommand
@@ -44,13 +46,26 @@ func RegisterCommandRecursive(parent *cobra.Command) {
 
 	RootCommand.AddCommand(NewFormatCommand())
 	RootCommand.AddCommand(NewLintCommand())
+	RootCommand.AddCommand(newSandboxedTestCommands()...)
 }
 
 func NewRootCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "jsonnet",
-		Short: "Helpers for linting and formatting JSONNet code",
+		Short: "Helpers for linting, formatting, and testing JSONNet code",
 	}
 	cmd.AddCommand(NewFormatCommand(), NewLintCommand())
+	cmd.AddCommand(newSandboxedTestCommands()...)
 	return cmd
 }
+
+// newSandboxedTestCommands returns the test command together with the hidden
+// worker command which evaluates its snippets in the jsonnetsecure sandbox.
+// Both must be added to the same parent.
+func newSandboxedTestCommands() []*cobra.Command {
+	worker := jsonnetsecure.NewJsonnetCmd()
+	return []*cobra.Command{
+		NewTestCommand(WithVMProvider(&sandboxProvider{worker: worker})),
+		worker,
+	}
+}
@@ -0,0 +1,565 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package jsonnetx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v2"
+	"github.com/goccy/go-yaml"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/jsonnetsecure"
+)
+
+const (
+	// TestFileSuffix is the suffix of Jsonnet files which are picked up by the
+	// test command. The expected output is read from the file with the same
+	// name and a ".json" extension, e.g. "mapper_test.jsonnet" is compared
+	// against "mapper_test.json".
+	TestFileSuffix = "_test.jsonnet"
+
+	TestOutputFormatHuman = "human"
+	TestOutputFormatJUnit = "junit"
+)
+
+type (
+	// TestCase describes a Jsonnet snippet, the variables it is evaluated with,
+	// and the JSON it is expected to produce.
+	TestCase struct {
+		// Name identifies the test case in the output.
+		Name string `json:"name"`
+
+		// Snippet is the Jsonnet code to evaluate. Either Snippet or File must be set.
+		Snippet string `json:"snippet,omitempty"`
+
+		// File is the path to a Jsonnet file to evaluate. Relative paths are
+		// resolved against the manifest's directory.
+		File string `json:"file,omitempty"`
+
+		ExtVars map[string]string `json:"ext_vars,omitempty"`
+		ExtCode map[string]string `json:"ext_code,omitempty"`
+		TLAVars map[string]string `json:"tla_vars,omitempty"`
+		TLACode map[string]string `json:"tla_code,omitempty"`
+
+		// Expected is the JSON output the snippet must evaluate to.
+		Expected json.RawMessage `json:"expected,omitempty"`
+
+		// ExpectedFile is the path to a file containing the expected JSON
+		// output. Relative paths are resolved against the manifest's directory.
+		ExpectedFile string `json:"expected_file,omitempty"`
+
+		// ExpectedError, if set, makes the test pass only if evaluation fails
+		// with an error containing this string.
+		ExpectedError string `json:"expected_error,omitempty"`
+	}
+
+	// TestManifest is a list of test cases, loaded from a JSON or YAML file.
+	TestManifest struct {
+		Tests []TestCase `json:"tests"`
+	}
+
+	// TestResult is the outcome of running a single TestCase.
+	TestResult struct {
+		Case     TestCase
+		Source   string
+		Actual   string
+		Diff     string
+		Err      error
+		Duration time.Duration
+	}
+
+	// TestOption configures the test command.
+	TestOption func(*testOptions)
+
+	testOptions struct {
+		provider jsonnetsecure.VMProvider
+	}
+
+	inProcessProvider struct{}
+
+	// sandboxProvider evaluates snippets in worker processes, which
+	// re-execute the current binary with the hidden worker command, in the
+	// same sandbox jsonnetsecure uses in production.
+	sandboxProvider struct {
+		worker *cobra.Command
+		once   sync.Once
+		pool   jsonnetsecure.Pool
+	}
+)
+
+// Passed returns true if the test case produced the expected output.
+func (r *TestResult) Passed() bool {
+	return r.Err == nil && r.Diff == ""
+}
+
+// WithVMProvider sets the provider used to create Jsonnet VMs. Pass the same
+// provider the application uses in production, e.g. a
+// jsonnetsecure.DefaultProvider with a process pool, to evaluate the tests
+// in the same sandbox. By default, snippets are evaluated in-process by a
+// jsonnetsecure VM with imports disabled.
+func WithVMProvider(p jsonnetsecure.VMProvider) TestOption {
+	return func(o *testOptions) {
+		o.provider = p
+	}
+}
+
+func (inProcessProvider) JsonnetVM(ctx context.Context) (jsonnetsecure.VM, error) {
+	return jsonnetsecure.MakeSecureVM(jsonnetsecure.WithContext(ctx)), nil
+}
+
+func (p *sandboxProvider) JsonnetVM(ctx context.Context) (jsonnetsecure.VM, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	p.once.Do(func() {
+		p.pool = jsonnetsecure.NewProcessPool(runtime.GOMAXPROCS(0))
+	})
+
+	// The first element of the command path is the name of the binary.
+	args := strings.Fields(p.worker.CommandPath())[1:]
+	return jsonnetsecure.MakeSecureVM(
+		jsonnetsecure.WithContext(ctx),
+		jsonnetsecure.WithJsonnetBinary(self),
+		jsonnetsecure.WithProcessArgs(args...),
+		jsonnetsecure.WithProcessPool(p.pool),
+	), nil
+}
+
+func (p *sandboxProvider) Close() {
+	if p.pool != nil {
+		p.pool.Close()
+	}
+}
+
+func NewTestCommand(opts ...TestOption) *cobra.Command {
+	o := &testOptions{provider: inProcessProvider{}}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var (
+		verbose, update                    bool
+		format                             string
+		extVars, extCode, tlaVars, tlaCode map[string]string
+	)
+	cmd := &cobra.Command{
+		Use: "test path/to/files/*_test.jsonnet [path/to/manifest.yaml, [supports/**/*_test.jsonnet]]",
+		Long: `Evaluates JSONNet snippets and compares their output against the expected JSON. Exits with a status code of 1 when a test fails.
+
+Files ending in "` + TestFileSuffix + `" are evaluated and compared against the file with the same name and a ".json"
+extension. Use --ext-str, --ext-code, --tla-str, and --tla-code to pass variables to these files, and -u or --update to
+(re-)write the expected output files.
+
+Files ending in ".json", ".yaml", or ".yml" are read as test manifests:
+
+	tests:
+	  - name: maps the email claim
+	    file: mapper.jsonnet
+	    ext_code:
+	      claims: '{"email": "foo@example.com"}'
+	    expected:
+	      identity:
+	        traits:
+	          email: foo@example.com
+
+Use --format junit to print the results as JUnit XML.
+
+` + GlobHelp,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != TestOutputFormatHuman && format != TestOutputFormatJUnit {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unknown output format %q, expected one of %q or %q.\n", format, TestOutputFormatHuman, TestOutputFormatJUnit)
+				return cmdx.FailSilently(cmd)
+			}
+
+			if c, ok := o.provider.(interface{ Close() }); ok {
+				defer c.Close()
+			}
+
+			var results []TestResult
+			for _, pattern := range args {
+				files, err := doublestar.Glob(pattern)
+				if err != nil {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Glob pattern %q is not valid: %s\n", pattern, err)
+					return cmdx.FailSilently(cmd)
+				}
+
+				for _, file := range files {
+					if fi, err := os.Stat(file); err != nil {
+						_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Matching file %q could not be opened: %s\n", file, err)
+						return cmdx.FailSilently(cmd)
+					} else if fi.IsDir() {
+						continue
+					}
+
+					if verbose {
+						_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Processing file: %s\n", file)
+					}
+
+					switch {
+					case strings.HasSuffix(file, TestFileSuffix):
+						tc := TestCase{
+							Name:         file,
+							File:         file,
+							ExpectedFile: goldenFile(file),
+							ExtVars:      extVars,
+							ExtCode:      extCode,
+							TLAVars:      tlaVars,
+							TLACode:      tlaCode,
+						}
+						if update {
+							if err := updateGoldenFile(cmd.Context(), o.provider, tc); err != nil {
+								_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unable to update expected output of %q: %s\n", file, err)
+								return cmdx.FailSilently(cmd)
+							}
+						}
+						results = append(results, RunTest(cmd.Context(), o.provider, "", tc))
+					case isGoldenFile(file):
+						// Expected output of a *_test.jsonnet file, not a manifest.
+						continue
+					case slices.Contains([]string{".json", ".yaml", ".yml"}, filepath.Ext(file)):
+						manifest, err := ReadTestManifest(file)
+						if err != nil {
+							_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unable to read test manifest %q: %s\n", file, err)
+							return cmdx.FailSilently(cmd)
+						}
+						for _, tc := range manifest.Tests {
+							results = append(results, RunTest(cmd.Context(), o.provider, file, tc))
+						}
+					default:
+						if verbose {
+							_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Skipping file %q because it is neither a test file nor a test manifest.\n", file)
+						}
+					}
+				}
+			}
+
+			var err error
+			if format == TestOutputFormatJUnit {
+				err = WriteTestResultsJUnit(cmd.OutOrStdout(), results)
+			} else {
+				err = WriteTestResults(cmd.OutOrStdout(), results, verbose)
+			}
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unable to write test results: %s\n", err)
+				return cmdx.FailSilently(cmd)
+			}
+
+			for _, r := range results {
+				if !r.Passed() {
+					return cmdx.FailSilently(cmd)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", TestOutputFormatHuman, `Output format, one of "human" or "junit".`)
+	cmd.Flags().BoolVarP(&update, "update", "u", false, "Write the evaluated output of *"+TestFileSuffix+" files to their expected output files.")
+	cmd.Flags().StringToStringVar(&extVars, "ext-str", nil, "External string variables passed to *"+TestFileSuffix+" files.")
+	cmd.Flags().StringToStringVar(&extCode, "ext-code", nil, "External code variables passed to *"+TestFileSuffix+" files.")
+	cmd.Flags().StringToStringVar(&tlaVars, "tla-str", nil, "Top-level string arguments passed to *"+TestFileSuffix+" files.")
+	cmd.Flags().StringToStringVar(&tlaCode, "tla-code", nil, "Top-level code arguments passed to *"+TestFileSuffix+" files.")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output.")
+	return cmd
+}
+
+// ReadTestManifest reads a JSON or YAML test manifest from the given path.
+func ReadTestManifest(path string) (*TestManifest, error) {
+	//#nosec G304 -- false positive
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	content, err = yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var manifest TestManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &manifest, nil
+}
+
+// RunTest evaluates the test case using a VM from the provider and compares the
+// result against the expected output. Relative file paths in the test case are
+// resolved against the directory of manifestPath, if set.
+func RunTest(ctx context.Context, provider jsonnetsecure.VMProvider, manifestPath string, tc TestCase) (result TestResult) {
+	start := time.Now()
+	result = TestResult{Case: tc, Source: manifestPath}
+	if result.Source == "" {
+		result.Source = tc.File
+	}
+	defer func() { result.Duration = time.Since(start) }()
+
+	if tc.Name == "" {
+		tc.Name = tc.File
+		result.Case.Name = tc.File
+	}
+
+	actual, err := evaluate(ctx, provider, manifestPath, tc)
+	if tc.ExpectedError != "" {
+		if err == nil {
+			result.Actual = actual
+			result.Err = errors.Errorf("expected evaluation to fail with %q but it succeeded", tc.ExpectedError)
+		} else if !strings.Contains(err.Error(), tc.ExpectedError) {
+			result.Err = errors.Errorf("expected evaluation to fail with %q but got: %s", tc.ExpectedError, err)
+		}
+		return result
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Actual = actual
+
+	expected := []byte(tc.Expected)
+	if tc.ExpectedFile != "" {
+		//#nosec G304 -- false positive
+		expected, err = os.ReadFile(resolvePath(manifestPath, tc.ExpectedFile))
+		if err != nil {
+			result.Err = errors.Wrap(err, "unable to read expected output")
+			return result
+		}
+	}
+	if len(expected) == 0 {
+		result.Err = errors.New("test case has neither expected output nor an expected error")
+		return result
+	}
+
+	result.Diff, err = DiffJSON(expected, []byte(actual))
+	if err != nil {
+		result.Err = err
+	}
+	return result
+}
+
+// DiffJSON returns a unified diff between the expected and actual JSON documents
+// after normalizing both, so that formatting and key order do not matter. The
+// diff is empty if both documents are semantically equal.
+func DiffJSON(expected, actual []byte) (string, error) {
+	e, err := normalizeJSON(expected)
+	if err != nil {
+		return "", errors.Wrap(err, "expected output is not valid JSON")
+	}
+	a, err := normalizeJSON(actual)
+	if err != nil {
+		return "", errors.Wrap(err, "actual output is not valid JSON")
+	}
+	if e == a {
+		return "", nil
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(e),
+		B:        difflib.SplitLines(a),
+		FromFile: "Expected",
+		ToFile:   "Actual",
+		Context:  3,
+	})
+}
+
+// WriteTestResults writes a human-readable summary of the test results.
+func WriteTestResults(w io.Writer, results []TestResult, verbose bool) error {
+	var b bytes.Buffer
+	var failed int
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			_, _ = fmt.Fprintf(&b, "FAIL %s (%s)\n    %s\n", r.Case.Name, r.Source, r.Err)
+		case r.Diff != "":
+			failed++
+			_, _ = fmt.Fprintf(&b, "FAIL %s (%s)\n", r.Case.Name, r.Source)
+			for _, line := range difflib.SplitLines(r.Diff) {
+				_, _ = fmt.Fprintf(&b, "    %s", line)
+			}
+		case verbose:
+			_, _ = fmt.Fprintf(&b, "PASS %s (%s) [%s]\n", r.Case.Name, r.Source, r.Duration.Round(time.Millisecond))
+		}
+	}
+	_, _ = fmt.Fprintf(&b, "\n%d passed, %d failed\n", len(results)-failed, failed)
+
+	_, err := w.Write(b.Bytes())
+	return errors.WithStack(err)
+}
+
+type (
+	junitTestSuites struct {
+		XMLName  xml.Name         `xml:"testsuites"`
+		Tests    int              `xml:"tests,attr"`
+		Failures int              `xml:"failures,attr"`
+		Suites   []junitTestSuite `xml:"testsuite"`
+	}
+	junitTestSuite struct {
+		Name     string          `xml:"name,attr"`
+		Tests    int             `xml:"tests,attr"`
+		Failures int             `xml:"failures,attr"`
+		Time     string          `xml:"time,attr"`
+		Cases    []junitTestCase `xml:"testcase"`
+	}
+	junitTestCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitFailure `xml:"failure,omitempty"`
+	}
+	junitFailure struct {
+		Message  string `xml:"message,attr"`
+		Contents string `xml:",chardata"`
+	}
+)
+
+// WriteTestResultsJUnit writes the test results as JUnit XML, with one test
+// suite per test file or manifest.
+func WriteTestResultsJUnit(w io.Writer, results []TestResult) error {
+	var (
+		suites  junitTestSuites
+		indexOf = map[string]int{}
+		elapsed = map[string]time.Duration{}
+	)
+	for _, r := range results {
+		i, ok := indexOf[r.Source]
+		if !ok {
+			i = len(suites.Suites)
+			indexOf[r.Source] = i
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: r.Source})
+		}
+		s := &suites.Suites[i]
+
+		tc := junitTestCase{
+			Name:      r.Case.Name,
+			ClassName: r.Source,
+			Time:      fmt.Sprintf("%.3f", r.Duration.Seconds()),
+		}
+		switch {
+		case r.Err != nil:
+			tc.Failure = &junitFailure{Message: r.Err.Error(), Contents: r.Err.Error()}
+		case r.Diff != "":
+			tc.Failure = &junitFailure{Message: "output does not match expected JSON", Contents: r.Diff}
+		}
+
+		s.Tests++
+		suites.Tests++
+		if tc.Failure != nil {
+			s.Failures++
+			suites.Failures++
+		}
+		elapsed[r.Source] += r.Duration
+		s.Cases = append(s.Cases, tc)
+	}
+	for i := range suites.Suites {
+		suites.Suites[i].Time = fmt.Sprintf("%.3f", elapsed[suites.Suites[i].Name].Seconds())
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return errors.WithStack(err)
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(suites); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return errors.WithStack(err)
+}
+
+func evaluate(ctx context.Context, provider jsonnetsecure.VMProvider, manifestPath string, tc TestCase) (string, error) {
+	filename, snippet := tc.Name, tc.Snippet
+	if tc.File != "" {
+		filename = resolvePath(manifestPath, tc.File)
+		//#nosec G304 -- false positive
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return "", errors.Wrap(err, "unable to read snippet")
+		}
+		snippet = string(content)
+	}
+	if snippet == "" {
+		return "", errors.New("test case has neither a snippet nor a file")
+	}
+
+	vm, err := provider.JsonnetVM(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create Jsonnet VM")
+	}
+	for k, v := range tc.ExtVars {
+		vm.ExtVar(k, v)
+	}
+	for k, v := range tc.ExtCode {
+		vm.ExtCode(k, v)
+	}
+	for k, v := range tc.TLAVars {
+		vm.TLAVar(k, v)
+	}
+	for k, v := range tc.TLACode {
+		vm.TLACode(k, v)
+	}
+
+	return vm.EvaluateAnonymousSnippet(filename, snippet)
+}
+
+func updateGoldenFile(ctx context.Context, provider jsonnetsecure.VMProvider, tc TestCase) error {
+	actual, err := evaluate(ctx, provider, "", tc)
+	if err != nil {
+		return err
+	}
+	normalized, err := normalizeJSON([]byte(actual))
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(os.WriteFile(tc.ExpectedFile, []byte(normalized+"\n"), 0o644)) // #nosec
+}
+
+func normalizeJSON(raw []byte) (string, error) {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", errors.WithStack(err)
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(out), nil
+}
+
+func goldenFile(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file)) + ".json"
+}
+
+// isGoldenFile reports whether the file is the expected output of a sibling
+// *_test.jsonnet file.
+func isGoldenFile(file string) bool {
+	if !strings.HasSuffix(file, strings.TrimSuffix(TestFileSuffix, ".jsonnet")+".json") {
+		return false
+	}
+	_, err := os.Stat(strings.TrimSuffix(file, ".json") + ".jsonnet")
+	return err == nil
+}
+
+func resolvePath(manifestPath, path string) string {
+	if manifestPath == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(manifestPath), path)
+}
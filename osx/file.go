@@ -4,11 +4,10 @@
 package osx
 
 import (
+	"context"
 	"encoding/base64"
-	"io"
 	"net/url"
-	"os"
-	"strings"
+	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 
@@ -17,6 +16,14 @@ import (
 	"github.com/ory/x/httpx"
 )
 
+const (
+	// DefaultReadTimeout is the default time after which reading a source
+	// is aborted.
+	DefaultReadTimeout = time.Minute
+	// DefaultMaxSourceBytes is the default maximum size of a remote source.
+	DefaultMaxSourceBytes = 10 << 20
+)
+
 type options struct {
 	disableFileLoader            bool
 	disableHTTPLoader            bool
@@ -24,6 +31,9 @@ type options struct {
 	base64enc                    *base64.Encoding
 	disableResilientBase64Loader bool
 	hc                           *retryablehttp.Client
+	registry                     *SchemeRegistry
+	timeout                      time.Duration
+	maxBytes                     int64
 }
 
 type Option func(o *options)
@@ -42,6 +52,8 @@ func newOptions() *options {
 		disableBase64Loader: false,
 		base64enc:           base64.RawURLEncoding,
 		hc:                  httpx.NewResilientClient(),
+		timeout:             DefaultReadTimeout,
+		maxBytes:            DefaultMaxSourceBytes,
 	}
 }
 
@@ -108,6 +120,23 @@ func WithHTTPClient(hc *retryablehttp.Client) Option {
 	}
 }
 
+// WithReadTimeout sets the time after which reading a source is aborted.
+// Defaults to DefaultReadTimeout.
+func WithReadTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithMaxSourceBytes sets the maximum size of remote sources, e.g. http(s) or
+// object store sources. Larger sources fail with bytes.ErrTooLarge. Defaults
+// to DefaultMaxSourceBytes.
+func WithMaxSourceBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBytes = n
+	}
+}
+
 // RestrictedReadFile works similar to ReadFileFromAllSources but has all
 // sources disabled per default. You need to enable the loaders you wish to use
 // explicitly.
@@ -141,6 +170,10 @@ func RestrictedReadFile(source string, opts ...Option) (bytes []byte, err error)
 // - WithDisabledBase64Loader
 // - WithBase64Encoding
 // - WithHTTPClient
+// - WithSchemeHandler
+// - WithSchemeRegistry
+// - WithReadTimeout
+// - WithMaxSourceBytes
 func ReadFileFromAllSources(source string, opts ...Option) (bytes []byte, err error) {
 	return readFile(source, newOptions().apply(opts))
 }
@@ -151,71 +184,65 @@ func readFile(source string, o *options) (bytes []byte, err error) {
 		return nil, errors.Wrap(err, "failed to parse URL")
 	}
 
+	// The loaders are checked before the handler is looked up, so that a
+	// registered handler can not bypass a disabled loader.
 	switch parsed.Scheme {
 	case "":
 		if o.disableFileLoader {
 			return nil, errors.New("file loader disabled")
 		}
-
-		//#nosec G304 -- false positive
-		bytes, err = os.ReadFile(source)
-		if err != nil {
-			return nil, errors.Wrap(err, "unable to read the file")
-		}
+		parsed = &url.URL{Scheme: "file", Path: source}
 	case "file":
 		if o.disableFileLoader {
 			return nil, errors.New("file loader disabled")
 		}
-
-		//#nosec G304 -- false positive
-		bytes, err = os.ReadFile(parsed.Host + parsed.Path)
-		if err != nil {
-			return nil, errors.Wrap(err, "unable to read the file")
-		}
 	case "http", "https":
 		if o.disableHTTPLoader {
 			return nil, errors.New("http(s) loader disabled")
 		}
-		resp, err := o.hc.Get(parsed.String())
-		if err != nil {
-			return nil, errors.Wrap(err, "unable to load remote file")
-		}
-		defer resp.Body.Close()
-
-		bytes, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, errors.Wrap(err, "unable to read the HTTP response body")
-		}
 	case "base64":
 		if o.disableBase64Loader {
 			return nil, errors.New("base64 loader disabled")
 		}
+	}
 
-		if o.disableResilientBase64Loader {
-			bytes, err = o.base64enc.DecodeString(strings.TrimPrefix(source, "base64://"))
-			if err != nil {
-				return nil, errors.Wrap(err, "unable to base64 decode the location")
-			}
-			return bytes, nil
-		}
-
-		for _, enc := range []*base64.Encoding{
-			base64.StdEncoding,
-			base64.URLEncoding,
-			base64.RawURLEncoding,
-			base64.RawStdEncoding,
-		} {
-			bytes, err = enc.DecodeString(strings.TrimPrefix(source, "base64://"))
-			if err == nil {
-				return bytes, nil
-			}
-		}
-
-		return nil, errors.Wrap(err, "unable to base64 decode the location")
-	default:
+	h, ok := o.registry.Lookup(parsed.Scheme)
+	if !ok {
+		h, ok = o.builtinSchemes().Lookup(parsed.Scheme)
+	}
+	if !ok {
 		return nil, errors.Errorf("unsupported source `%s`", parsed.Scheme)
 	}
 
-	return bytes, nil
+	ctx := context.Background()
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	if o.maxBytes > 0 {
+		ctx = ContextWithMaxSourceBytes(ctx, o.maxBytes)
+	}
+	return h.ReadSource(ctx, parsed)
+}
+
+// builtinSchemes returns the handlers of the built-in loaders, which are used
+// for the schemes without a registered handler.
+func (o *options) builtinSchemes() *SchemeRegistry {
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+		base64.RawStdEncoding,
+	}
+	if o.disableResilientBase64Loader {
+		encodings = []*base64.Encoding{o.base64enc}
+	}
 
+	r := NewSchemeRegistry()
+	r.Register("file", FileSchemeHandler())
+	r.Register("http", HTTPSchemeHandler(o.hc))
+	r.Register("https", HTTPSchemeHandler(o.hc))
+	r.Register("base64", Base64SchemeHandler(encodings...))
+	return r
 }
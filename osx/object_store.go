@@ -0,0 +1,127 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package osx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/httpx"
+)
+
+const (
+	// S3Endpoint is the endpoint of Amazon S3. Buckets in other regions
+	// redirect to their regional endpoint.
+	S3Endpoint = "https://s3.amazonaws.com"
+	// GCSEndpoint is the XML API endpoint of Google Cloud Storage.
+	GCSEndpoint = "https://storage.googleapis.com"
+	// DefaultObjectStoreMaxBytes is the default maximum size of an object.
+	DefaultObjectStoreMaxBytes = 10 << 20
+)
+
+type (
+	objectStoreOptions struct {
+		hc       *retryablehttp.Client
+		edit     func(*http.Request) error
+		maxBytes int64
+	}
+
+	// ObjectStoreOption configures ObjectStoreSchemeHandler.
+	ObjectStoreOption func(o *objectStoreOptions)
+)
+
+// WithObjectStoreHTTPClient sets the HTTP client used to download objects.
+func WithObjectStoreHTTPClient(hc *retryablehttp.Client) ObjectStoreOption {
+	return func(o *objectStoreOptions) {
+		o.hc = hc
+	}
+}
+
+// WithObjectStoreRequestEditor edits every request before it is sent, e.g. to
+// sign it or to add an authorization header.
+func WithObjectStoreRequestEditor(edit func(*http.Request) error) ObjectStoreOption {
+	return func(o *objectStoreOptions) {
+		o.edit = edit
+	}
+}
+
+// WithObjectStoreMaxBytes sets the maximum size of an object in bytes. Larger
+// objects fail with bytes.ErrTooLarge. Defaults to DefaultObjectStoreMaxBytes.
+// A lower limit set with ContextWithMaxSourceBytes takes precedence.
+func WithObjectStoreMaxBytes(n int64) ObjectStoreOption {
+	return func(o *objectStoreOptions) {
+		o.maxBytes = n
+	}
+}
+
+// ObjectStoreSchemeHandler returns a handler which downloads objects from an
+// object store with a path-style HTTP API, which Amazon S3, Google Cloud
+// Storage, and most S3-compatible stores support. A source of the form
+// s3://bucket/path/to/object is downloaded from <endpoint>/bucket/path/to/object.
+//
+// Objects are downloaded anonymously unless a request editor is set.
+//
+//	registry.Register("s3", osx.ObjectStoreSchemeHandler(osx.S3Endpoint))
+//	registry.Register("gs", osx.ObjectStoreSchemeHandler(osx.GCSEndpoint))
+func ObjectStoreSchemeHandler(endpoint string, opts ...ObjectStoreOption) SchemeHandler {
+	o := &objectStoreOptions{hc: httpx.NewResilientClient(), maxBytes: DefaultObjectStoreMaxBytes}
+	for _, f := range opts {
+		f(o)
+	}
+
+	return SchemeHandlerFunc(func(ctx context.Context, source *url.URL) ([]byte, error) {
+		if source.Host == "" || strings.Trim(source.Path, "/") == "" {
+			return nil, errors.Errorf("object store source must be of the form %s://bucket/object", source.Scheme)
+		}
+
+		u, err := url.Parse(strings.TrimSuffix(endpoint, "/") + "/" + url.PathEscape(source.Host) + source.EscapedPath())
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to build the object URL")
+		}
+
+		req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if o.edit != nil {
+			if err := o.edit(req.Request); err != nil {
+				return nil, errors.Wrap(err, "unable to edit the object store request")
+			}
+		}
+
+		res, err := o.hc.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load the object")
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("expected http response status code 200 but got %d when loading object %s/%s", res.StatusCode, source.Host, strings.TrimPrefix(source.Path, "/"))
+		}
+
+		limit := o.maxBytes
+		if n, ok := MaxSourceBytesFromContext(ctx); ok && (limit <= 0 || n < limit) {
+			limit = n
+		}
+		if limit <= 0 {
+			limit = DefaultObjectStoreMaxBytes
+		}
+
+		b, err := io.ReadAll(io.LimitReader(res.Body, limit+1))
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the object")
+		}
+		if int64(len(b)) > limit {
+			return nil, errors.Wrapf(bytes.ErrTooLarge, "object %s/%s is larger than %d bytes", source.Host, strings.TrimPrefix(source.Path, "/"), limit)
+		}
+		return b, nil
+	})
+}
@@ -0,0 +1,206 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package osx
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+)
+
+type (
+	// SchemeHandler reads the contents of a source with a custom URL scheme,
+	// e.g. s3://bucket/key. Handlers should read at most the number of bytes
+	// returned by MaxSourceBytesFromContext.
+	SchemeHandler interface {
+		ReadSource(ctx context.Context, source *url.URL) ([]byte, error)
+	}
+
+	// SchemeHandlerFunc is an adapter to use ordinary functions as
+	// SchemeHandler.
+	SchemeHandlerFunc func(ctx context.Context, source *url.URL) ([]byte, error)
+
+	// SchemeRegistry maps URL schemes to their handlers. The same registry
+	// can be passed to ReadFileFromAllSources and fetcher.Fetcher, so that
+	// both support the same schemes. It is safe for concurrent use.
+	SchemeRegistry struct {
+		mu       sync.RWMutex
+		handlers map[string]SchemeHandler
+	}
+
+	maxSourceBytesContextKey struct{}
+)
+
+// ContextWithMaxSourceBytes returns a context which asks scheme handlers to
+// read at most n bytes from a source. fetcher.Fetcher sets it to the limit of
+// fetcher.WithMaxHTTPMaxBytes.
+func ContextWithMaxSourceBytes(ctx context.Context, n int64) context.Context {
+	return context.WithValue(ctx, maxSourceBytesContextKey{}, n)
+}
+
+// MaxSourceBytesFromContext returns the limit set with
+// ContextWithMaxSourceBytes, if any.
+func MaxSourceBytesFromContext(ctx context.Context) (int64, bool) {
+	n, ok := ctx.Value(maxSourceBytesContextKey{}).(int64)
+	return n, ok && n > 0
+}
+
+func (f SchemeHandlerFunc) ReadSource(ctx context.Context, source *url.URL) ([]byte, error) {
+	return f(ctx, source)
+}
+
+// NewSchemeRegistry returns an empty registry.
+func NewSchemeRegistry() *SchemeRegistry {
+	return &SchemeRegistry{handlers: map[string]SchemeHandler{}}
+}
+
+// Register registers the handler for the scheme, replacing any previously
+// registered handler. Registered handlers take precedence over the built-in
+// handlers, but not over disabled loaders or allowed schemes.
+func (r *SchemeRegistry) Register(scheme string, h SchemeHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[strings.ToLower(scheme)] = h
+}
+
+// Lookup returns the handler registered for the scheme. It is safe to call on
+// a nil registry.
+func (r *SchemeRegistry) Lookup(scheme string) (SchemeHandler, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.handlers[strings.ToLower(scheme)]
+	return h, ok
+}
+
+// Schemes returns the registered schemes in lexical order. It is safe to call
+// on a nil registry.
+func (r *SchemeRegistry) Schemes() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Sorted(maps.Keys(r.handlers))
+}
+
+// WithSchemeRegistry dispatches sources with the schemes registered in the
+// registry to their handlers.
+func WithSchemeRegistry(r *SchemeRegistry) Option {
+	return func(o *options) {
+		o.registry = r
+	}
+}
+
+// WithSchemeHandler registers the handler for the scheme. If a registry was
+// set with WithSchemeRegistry, the handler is registered there.
+func WithSchemeHandler(scheme string, h SchemeHandler) Option {
+	return func(o *options) {
+		if o.registry == nil {
+			o.registry = NewSchemeRegistry()
+		}
+		o.registry.Register(scheme, h)
+	}
+}
+
+// FileSchemeHandler returns a handler which reads sources of the form
+// file:///path/to/file from the file system.
+func FileSchemeHandler() SchemeHandler {
+	return SchemeHandlerFunc(func(_ context.Context, source *url.URL) ([]byte, error) {
+		//#nosec G304 -- reading the file is the purpose of the handler
+		b, err := os.ReadFile(source.Host + source.Path)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the file")
+		}
+		return b, nil
+	})
+}
+
+// HTTPSchemeHandler returns a handler which loads http and https sources with
+// the client. It reads at most the number of bytes set with
+// ContextWithMaxSourceBytes.
+func HTTPSchemeHandler(hc *retryablehttp.Client) SchemeHandler {
+	return SchemeHandlerFunc(func(ctx context.Context, source *url.URL) ([]byte, error) {
+		req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		res, err := hc.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load remote file")
+		}
+		defer res.Body.Close()
+
+		b, err := readLimited(ctx, res.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the HTTP response body")
+		}
+		return b, nil
+	})
+}
+
+// Base64SchemeHandler returns a handler which decodes sources of the form
+// base64://<encoded contents> with the first of the encodings which succeeds.
+func Base64SchemeHandler(encodings ...*base64.Encoding) SchemeHandler {
+	return SchemeHandlerFunc(func(_ context.Context, source *url.URL) (b []byte, err error) {
+		err = errors.New("no base64 encoding configured")
+		for _, enc := range encodings {
+			b, err = enc.DecodeString(source.Host + source.Path)
+			if err == nil {
+				return b, nil
+			}
+		}
+		return nil, errors.Wrap(err, "unable to base64 decode the location")
+	})
+}
+
+// readLimited reads r up to the limit set with ContextWithMaxSourceBytes, if
+// any, and fails with bytes.ErrTooLarge if r is larger.
+func readLimited(ctx context.Context, r io.Reader) ([]byte, error) {
+	limit, ok := MaxSourceBytesFromContext(ctx)
+	if !ok {
+		return io.ReadAll(r)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limit {
+		return nil, errors.Wrapf(bytes.ErrTooLarge, "source is larger than %d bytes", limit)
+	}
+	return b, nil
+}
+
+// EnvSchemeHandler returns a handler which reads sources of the form
+// env://NAME from the environment variable NAME. Unset variables are an
+// error, empty ones are not.
+//
+// The handler is not registered by default, because it allows anyone who
+// controls the source to read the process environment.
+func EnvSchemeHandler() SchemeHandler {
+	return SchemeHandlerFunc(func(_ context.Context, source *url.URL) ([]byte, error) {
+		name := source.Host + source.Path
+		if name == "" {
+			return nil, errors.New("env source is missing the variable name")
+		}
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, errors.Errorf("environment variable %s is not set", name)
+		}
+		return []byte(v), nil
+	})
+}
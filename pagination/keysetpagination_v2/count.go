@@ -0,0 +1,179 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package keysetpagination
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/pop/v6"
+)
+
+type CountStrategy int
+
+const (
+	// CountStrategyNone does not count the items. This is the default.
+	CountStrategyNone CountStrategy = iota
+	// CountStrategyExact counts the items matching the query with COUNT(*).
+	CountStrategyExact
+	// CountStrategyEstimate asks the database for the planner's row estimate
+	// of the query on PostgreSQL and CockroachDB. Other dialects, and
+	// CockroachDB without table statistics, fall back to an exact count.
+	CountStrategyEstimate
+)
+
+const (
+	HeaderTotalCount         = "X-Total-Count"
+	HeaderTotalCountEstimate = "X-Total-Count-Estimate"
+)
+
+type totalCount struct {
+	count     int64
+	estimated bool
+}
+
+// Count counts the items matching the query according to the paginator's count
+// strategy and stores the result on the paginator. The result is carried over
+// to the paginator returned by Result, and SetLinkHeader writes it to the
+// X-Total-Count or X-Total-Count-Estimate header.
+//
+// Count must be called on the query before the Paginate scope is applied,
+// because the scope adds the page token's conditions and the limit to the
+// query. Count is not part of Paginate, because a pop.ScopeFunc can not return
+// the error of the count query.
+// Usage:
+//
+//	q := c.Where("foo = ?", foo)
+//	if err := keysetpagination.Count[MyItemType](q, paginator); err != nil {
+//		return err
+//	}
+//	err := q.Scope(keysetpagination.Paginate[MyItemType](paginator)).All(&items)
+func Count[I any](q *pop.Query, p *Paginator) error {
+	switch p.countStrategy {
+	case CountStrategyNone:
+		return nil
+	case CountStrategyExact:
+		return countExact[I](q, p)
+	case CountStrategyEstimate:
+		var (
+			estimate int64
+			err      error
+		)
+		switch q.Connection.Dialect.Name() {
+		case "postgres":
+			estimate, err = estimatePostgres[I](q)
+		case "cockroach":
+			var ok bool
+			estimate, ok, err = estimateCockroach[I](q)
+			if err == nil && !ok {
+				// The table has no statistics yet, e.g. because it was just
+				// created, so it is most likely small enough to count.
+				return countExact[I](q, p)
+			}
+		default:
+			return countExact[I](q, p)
+		}
+		if err != nil {
+			return err
+		}
+		p.total = &totalCount{count: estimate, estimated: true}
+		return nil
+	default:
+		return errors.Errorf("keyset pagination: unknown count strategy %d", p.countStrategy)
+	}
+}
+
+func countExact[I any](q *pop.Query, p *Paginator) error {
+	count, err := q.Count(new(I))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	p.total = &totalCount{count: int64(count)}
+	return nil
+}
+
+// estimatePostgres returns the planner's row estimate for the query. The
+// estimate takes the query's conditions into account, but may be off by a
+// large factor if the table statistics are outdated.
+func estimatePostgres[I any](q *pop.Query) (int64, error) {
+	query, args := q.ToSQL(pop.NewModel(new(I), q.Connection.Context()))
+
+	var plan string
+	if err := q.Connection.RawQuery("EXPLAIN (FORMAT JSON) "+query, args...).First(&plan); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	var parsed []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(plan), &parsed); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if len(parsed) == 0 {
+		return 0, errors.New("keyset pagination: EXPLAIN returned no plan")
+	}
+	return int64(parsed[0].Plan.Rows), nil
+}
+
+// estimateCockroach returns the planner's row estimate for the query, which
+// is the estimate of the plan's root node. The planner only estimates rows if
+// the table has statistics, otherwise ok is false.
+func estimateCockroach[I any](q *pop.Query) (estimate int64, ok bool, err error) {
+	query, args := q.ToSQL(pop.NewModel(new(I), q.Connection.Context()))
+
+	var plan []string
+	if err := q.Connection.RawQuery("EXPLAIN "+query, args...).All(&plan); err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+
+	for _, line := range plan {
+		// e.g. "  │ estimated row count: 1,234 (12% of the table; stats collected 3 minutes ago)"
+		_, rows, found := strings.Cut(line, "estimated row count: ")
+		if !found {
+			continue
+		}
+		rows, _, _ = strings.Cut(rows, " ")
+		estimate, err := strconv.ParseInt(strings.ReplaceAll(rows, ",", ""), 10, 64)
+		if err != nil {
+			return 0, false, errors.Wrapf(err, "keyset pagination: unable to parse the row estimate of %q", line)
+		}
+		return estimate, true, nil
+	}
+	return 0, false, nil
+}
+
+// TotalCount returns the number of items counted by Count, whether it is an
+// estimate, and whether the items were counted at all.
+func (p *Paginator) TotalCount() (count int64, estimated, ok bool) {
+	if p.total == nil {
+		return 0, false, false
+	}
+	return p.total.count, p.total.estimated, true
+}
+
+func (p *Paginator) CountStrategy() CountStrategy { return p.countStrategy }
+
+// WithCountStrategy sets how Count counts the items. Counting is opt-in because
+// even estimates cost an additional query per page.
+func WithCountStrategy(s CountStrategy) Option {
+	return func(p *Paginator) { p.countStrategy = s }
+}
+
+func setCountHeader(w http.ResponseWriter, p *Paginator) {
+	count, estimated, ok := p.TotalCount()
+	if !ok {
+		return
+	}
+	if estimated {
+		w.Header().Set(HeaderTotalCountEstimate, strconv.FormatInt(count, 10))
+	} else {
+		w.Header().Set(HeaderTotalCount, strconv.FormatInt(count, 10))
+	}
+}
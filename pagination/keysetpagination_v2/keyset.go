@@ -0,0 +1,85 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package keysetpagination
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/ory/x/contextx"
+)
+
+type (
+	// Keyset holds the keys used to encrypt and decrypt page tokens. New page
+	// tokens are always encrypted with the active key. Decrypt-only keys are
+	// kept during key rotation, so that page tokens encrypted with a previous
+	// key remain valid until they expire.
+	//
+	// The zero value uses the well-known fallback key, which only obfuscates
+	// page tokens.
+	Keyset struct {
+		Active      [32]byte
+		DecryptOnly [][32]byte
+	}
+
+	// Audience binds a page token to the endpoint and network it was issued
+	// for. A page token issued for one audience is rejected by all others,
+	// which prevents replaying it against another list endpoint or tenant.
+	Audience struct {
+		// Route identifies the endpoint, e.g. "GET /admin/identities".
+		Route string
+		// NID is the network ID the page token was issued for.
+		NID uuid.UUID
+	}
+)
+
+// NewKeyset returns a keyset that uses the first key as the active key and all
+// other keys as decrypt-only keys. This matches how page tokens were handled
+// before explicit keysets were introduced.
+func NewKeyset(keys [][32]byte) Keyset {
+	if len(keys) == 0 {
+		return Keyset{}
+	}
+	return Keyset{Active: keys[0], DecryptOnly: keys[1:]}
+}
+
+// NewAudience returns the audience for the given route and the network ID
+// resolved by the contextualizer.
+func NewAudience(ctx context.Context, c contextx.Contextualizer, nid uuid.UUID, route string) Audience {
+	return Audience{Route: route, NID: c.Network(ctx, nid)}
+}
+
+// KeyID returns the ID of the key which is embedded in the page token. It is
+// derived from the key so that it does not need to be configured.
+func KeyID(key [32]byte) string {
+	sum := sha256.Sum256(append([]byte(pageTokenContext+"/key_id\x00"), key[:]...))
+	return hex.EncodeToString(sum[:4])
+}
+
+// decryptionKeys returns the keys which may decrypt a page token: the active
+// key and the decrypt-only keys. The fallback key is only used if no keys are
+// configured, because anyone can seal page tokens with it.
+func (ks Keyset) decryptionKeys() [][32]byte {
+	if ks.Active == fallbackEncryptionKey && len(ks.DecryptOnly) == 0 {
+		return [][32]byte{fallbackEncryptionKey}
+	}
+
+	keys := make([][32]byte, 0, len(ks.DecryptOnly)+1)
+	if ks.Active != fallbackEncryptionKey {
+		keys = append(keys, ks.Active)
+	}
+	return append(keys, ks.DecryptOnly...)
+}
+
+// additionalData is passed to the AEAD, so that a page token can only be
+// decrypted for the audience it was encrypted for.
+func (a Audience) additionalData() []byte {
+	if a == (Audience{}) {
+		return []byte(pageTokenContext)
+	}
+	return []byte(pageTokenContext + "\x00" + a.Route + "\x00" + a.NID.String())
+}
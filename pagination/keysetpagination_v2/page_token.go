@@ -22,9 +22,13 @@ import (
 // It binds the ciphertext to its purpose: even if the encryption key is
 // reused in another context (for example when it is derived from a shared
 // system secret), ciphertexts from that context are rejected as page tokens
-// and vice versa.
+// and vice versa. See Audience for binding tokens to an endpoint and network.
 const pageTokenContext = "ory/keysetpagination_v2/page_token"
 
+// keyIDSeparator separates the key ID from the sealed page token. It is not
+// part of the URL-safe base64 alphabet.
+const keyIDSeparator = "."
+
 // fallbackEncryptionKey seals page tokens when no pagination secrets are
 // configured. It is well known, so those tokens are only obfuscated, not
 // authenticated. Configure pagination secrets to authenticate tokens.
@@ -32,12 +36,14 @@ var fallbackEncryptionKey [32]byte
 
 type (
 	PageToken struct {
-		testNow func() time.Time
-		cols    []Column
+		testNow   func() time.Time
+		cols      []Column
+		direction Direction
 	}
 	jsonPageToken = struct {
 		ExpiresAt time.Time    `json:"e"`
 		Cols      []jsonColumn `json:"c"`
+		Direction Direction    `json:"d,omitempty"`
 	}
 
 	jsonColumn = struct {
@@ -62,16 +68,25 @@ type (
 	}
 )
 
-func (t PageToken) Columns() []Column { return t.cols }
+func (t PageToken) Columns() []Column    { return t.cols }
+func (t PageToken) Direction() Direction { return t.direction }
+
+// WithDirection returns a copy of the page token which paginates in the given direction.
+func (t PageToken) WithDirection(d Direction) PageToken {
+	t.direction = d
+	return t
+}
 
 // Encrypt encrypts the page token using the first key in the provided keyset.
 // It uses a fallback key if no keys are provided.
 func (t PageToken) Encrypt(keys [][32]byte) string {
-	key := fallbackEncryptionKey
-	if len(keys) > 0 {
-		key = keys[0]
-	}
-	enc, err := t.encrypt(key)
+	return t.EncryptWithKeyset(NewKeyset(keys), Audience{})
+}
+
+// EncryptWithKeyset encrypts the page token using the keyset's active key and
+// binds it to the audience. The page token carries the ID of the key.
+func (t PageToken) EncryptWithKeyset(ks Keyset, aud Audience) string {
+	enc, err := t.encrypt(ks.Active, aud)
 	if err != nil {
 		// This should basically never happen, only if reading from the random source or marshaling the token fails.
 		// In both cases, we have a bigger problem than just not being able to generate the page token.
@@ -91,6 +106,7 @@ func (t PageToken) MarshalJSON() ([]byte, error) {
 	toEncode := jsonPageToken{
 		ExpiresAt: now().Add(time.Hour).UTC(),
 		Cols:      make([]jsonColumn, len(t.cols)),
+		Direction: t.direction,
 	}
 	for i, col := range t.cols {
 		toEncode.Cols[i] = jsonColumn{
@@ -141,6 +157,10 @@ func (t *PageToken) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	t.cols = make([]Column, len(rawToken.Cols))
+	t.direction = rawToken.Direction
+	if t.direction != DirectionForward && t.direction != DirectionBackward {
+		return errors.WithStack(ErrInvalidPaginationToken())
+	}
 	for i, col := range rawToken.Cols {
 		t.cols[i] = Column{
 			Name:          col.Name,
@@ -174,7 +194,7 @@ func (t *PageToken) UnmarshalJSON(data []byte) error {
 
 func NewPageToken(cols ...Column) PageToken { return PageToken{cols: cols} }
 
-func (t *PageToken) encrypt(key [32]byte) (string, error) {
+func (t *PageToken) encrypt(key [32]byte, aud Audience) (string, error) {
 	raw, err := json.Marshal(t)
 	if err != nil {
 		return "", errors.Wrap(err, "cannot marshal page token")
@@ -192,10 +212,10 @@ func (t *PageToken) encrypt(key [32]byte) (string, error) {
 		return "", errors.Wrap(err, "cannot generate nonce")
 	}
 
-	return base64.URLEncoding.EncodeToString(a.Seal(nonce, nonce, raw, []byte(pageTokenContext))), nil
+	return KeyID(key) + keyIDSeparator + base64.URLEncoding.EncodeToString(a.Seal(nonce, nonce, raw, aud.additionalData())), nil
 }
 
-func (t *PageToken) decrypt(key [32]byte, s string) error {
+func (t *PageToken) decrypt(key [32]byte, aud Audience, s string) error {
 	if s == "" {
 		return errors.WithStack(ErrInvalidPaginationToken())
 	}
@@ -205,7 +225,7 @@ func (t *PageToken) decrypt(key [32]byte, s string) error {
 		return errors.WithStack(ErrInvalidPaginationToken())
 	}
 
-	dec, err := openAEAD(key, raw)
+	dec, err := openAEAD(key, raw, aud.additionalData())
 	if err != nil {
 		// Tokens issued before the switch to a context-bound AEAD are sealed
 		// with NaCl secretbox. Remove this fallback once all tokens issued by
@@ -227,7 +247,7 @@ func (t *PageToken) decrypt(key [32]byte, s string) error {
 	return nil
 }
 
-func openAEAD(key [32]byte, raw []byte) ([]byte, error) {
+func openAEAD(key [32]byte, raw, additionalData []byte) ([]byte, error) {
 	a, err := aead.New(key)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot create AEAD")
@@ -236,7 +256,7 @@ func openAEAD(key [32]byte, raw []byte) ([]byte, error) {
 		return nil, errors.New("ciphertext too short")
 	}
 	nonce, ciphertext := raw[:a.NonceSize()], raw[a.NonceSize():]
-	bs, err := a.Open(nil, nonce, ciphertext, []byte(pageTokenContext))
+	bs, err := a.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot open AEAD")
 	}
@@ -6,6 +6,7 @@ package keysetpagination
 import (
 	"cmp"
 	"reflect"
+	"slices"
 
 	"github.com/jmoiron/sqlx/reflectx"
 
@@ -18,9 +19,11 @@ func ErrInvalidPaginationToken() *herodot.DefaultError {
 
 type (
 	Paginator struct {
-		token, defaultToken        PageToken
-		size, defaultSize, maxSize int
-		isLast                     bool
+		token, defaultToken, prevToken PageToken
+		size, defaultSize, maxSize     int
+		isLast, rowValues              bool
+		countStrategy                  CountStrategy
+		total                          *totalCount
 	}
 	Option func(*Paginator)
 )
@@ -35,6 +38,12 @@ var dbStructTagMapper = reflectx.NewMapper("db")
 func (p *Paginator) DefaultToken() PageToken { return p.defaultToken }
 func (p *Paginator) IsLast() bool            { return p.isLast }
 
+// PrevPageToken returns the token of the previous page and whether a previous
+// page exists. It is only set on paginators returned by Result and ResultFunc.
+func (p *Paginator) PrevPageToken() (PageToken, bool) {
+	return p.prevToken, p.prevToken.cols != nil
+}
+
 func (p *Paginator) PageToken() PageToken {
 	if p.token.cols != nil {
 		return p.token
@@ -58,7 +67,7 @@ func (p *Paginator) Size() int {
 }
 
 func (p *Paginator) ToOptions() []Option {
-	opts := make([]Option, 0, 6)
+	opts := make([]Option, 0, 10)
 	if p.token.cols != nil {
 		opts = append(opts, WithToken(p.token))
 	}
@@ -77,10 +86,23 @@ func (p *Paginator) ToOptions() []Option {
 	if p.isLast {
 		opts = append(opts, withIsLast(p.isLast))
 	}
+	if p.prevToken.cols != nil {
+		opts = append(opts, withPrevToken(p.prevToken))
+	}
+	if p.rowValues {
+		opts = append(opts, WithRowValueComparison())
+	}
+	if p.countStrategy != CountStrategyNone {
+		opts = append(opts, WithCountStrategy(p.countStrategy))
+	}
+	if p.total != nil {
+		opts = append(opts, withTotalCount(p.total))
+	}
 	return opts
 }
 
 // Result removes the last item (if applicable) and returns the paginator for the next page.
+// For backward pages, the items are flipped back into the paginator's order.
 func Result[I any](items []I, p *Paginator) ([]I, *Paginator) {
 	return ResultFunc(items, p, func(last I, colName string) any {
 		lastItemVal := reflect.ValueOf(last)
@@ -89,22 +111,49 @@ func Result[I any](items []I, p *Paginator) ([]I, *Paginator) {
 }
 
 // ResultFunc removes the last item (if applicable) and returns the paginator for the next page.
-// The extractor function is used to extract the column values from the last item.
+// For backward pages, the items are flipped back into the paginator's order.
+// The returned paginator also carries the token of the previous page, if one exists.
+// The extractor function is used to extract the column values from the first and last item.
 func ResultFunc[I any](items []I, p *Paginator, extractor func(last I, colName string) any) ([]I, *Paginator) {
-	if len(items) <= p.Size() {
-		return items, &Paginator{
-			isLast: true,
-
-			defaultToken: p.defaultToken,
-			size:         p.size,
-			defaultSize:  p.defaultSize,
-			maxSize:      p.maxSize,
-		}
+	backward := p.PageToken().Direction() == DirectionBackward
+	hasMore := len(items) > p.Size()
+	if hasMore {
+		items = items[:p.Size()]
+	}
+	if backward {
+		slices.Reverse(items)
+	}
+
+	next := &Paginator{
+		defaultToken:  p.defaultToken,
+		size:          p.size,
+		defaultSize:   p.defaultSize,
+		maxSize:       p.maxSize,
+		rowValues:     p.rowValues,
+		countStrategy: p.countStrategy,
+		total:         p.total,
+	}
+	if len(items) == 0 {
+		next.isLast = true
+		return items, next
+	}
+
+	// A backward page was reached from a later page, so there always is a next page.
+	if hasMore || backward {
+		next.token = tokenFromItem(p, items[len(items)-1], extractor, DirectionForward)
+	} else {
+		next.isLast = true
+	}
+
+	// A forward page reached through a page token always has a previous page.
+	if (backward && hasMore) || (!backward && p.token.cols != nil) {
+		next.prevToken = tokenFromItem(p, items[0], extractor, DirectionBackward)
 	}
 
-	items = items[:p.Size()]
-	lastItem := items[len(items)-1]
+	return items, next
+}
 
+func tokenFromItem[I any](p *Paginator, item I, extractor func(last I, colName string) any, direction Direction) PageToken {
 	currentCols := p.PageToken().Columns()
 	newCols := make([]Column, len(currentCols))
 	for i, col := range currentCols {
@@ -113,17 +162,10 @@ func ResultFunc[I any](items []I, p *Paginator, extractor func(last I, colName s
 			Order:         col.Order,
 			Nullable:      col.Nullable,
 			HasConstraint: col.HasConstraint,
-			Value:         extractor(lastItem, col.Name),
+			Value:         extractor(item, col.Name),
 		}
 	}
-
-	return items, &Paginator{
-		token:        NewPageToken(newCols...),
-		defaultToken: p.defaultToken,
-		size:         p.size,
-		defaultSize:  p.defaultSize,
-		maxSize:      p.maxSize,
-	}
+	return NewPageToken(newCols...).WithDirection(direction)
 }
 
 func WithSize(size int) Option {
@@ -146,10 +188,25 @@ func WithDefaultToken(t PageToken) Option {
 	return func(p *Paginator) { p.defaultToken = t }
 }
 
+// WithRowValueComparison makes Paginate use BuildWhereAndOrderRowValues, which
+// emits index-friendly row-value comparisons where the dialect and columns
+// allow it.
+func WithRowValueComparison() Option {
+	return func(p *Paginator) { p.rowValues = true }
+}
+
 func withIsLast(isLast bool) Option {
 	return func(p *Paginator) { p.isLast = isLast }
 }
 
+func withPrevToken(t PageToken) Option {
+	return func(p *Paginator) { p.prevToken = t }
+}
+
+func withTotalCount(t *totalCount) Option {
+	return func(p *Paginator) { p.total = t }
+}
+
 func NewPaginator(modifiers ...Option) (*Paginator, error) {
 	p := &Paginator{
 		// these can still be overridden by the modifiers, but they should never be unset
@@ -10,12 +10,14 @@ import (
 	"github.com/peterhellberg/link"
 )
 
-// ParseHeader parses the response header's Link and returns the first and next page tokens.
-func ParseHeader(r *http.Response) (first, next string, isLast bool) {
+// ParseHeader parses the response header's Link and returns the first, previous, and next page tokens.
+// The previous page token is empty if there is no previous page.
+func ParseHeader(r *http.Response) (first, prev, next string, isLast bool) {
 	links := link.ParseResponse(r)
 	first, _ = findRel(links, "first")
+	prev, _ = findRel(links, "prev")
 	next, hasNext := findRel(links, "next")
-	return first, next, !hasNext
+	return first, prev, next, !hasNext
 }
 
 func findRel(links link.Group, rel string) (string, bool) {
@@ -11,13 +11,23 @@ import (
 	"github.com/ory/pop/v6"
 )
 
-type Order int
+type (
+	Order     int
+	Direction int
+)
 
 const (
 	OrderAscending Order = iota
 	OrderDescending
 )
 
+const (
+	// DirectionForward selects the rows after the page token, i.e. the next page.
+	DirectionForward Direction = iota
+	// DirectionBackward selects the rows before the page token, i.e. the previous page.
+	DirectionBackward
+)
+
 func (o Order) extract() (string, string) {
 	switch o {
 	case OrderAscending:
@@ -29,7 +39,16 @@ func (o Order) extract() (string, string) {
 	}
 }
 
-// Paginate returns a function that paginates a pop.Query.
+func (o Order) reverse() Order {
+	if o == OrderAscending {
+		return OrderDescending
+	}
+	return OrderAscending
+}
+
+// Paginate returns a function that paginates a pop.Query. It does not count
+// the items; call Count before applying the scope if the paginator has a count
+// strategy.
 // Usage:
 //
 //	q := c.Where("foo = ?", foo).Scope(keysetpagination.Paginate[MyItemType](paginator))
@@ -42,23 +61,51 @@ func Paginate[I any](p *Paginator) pop.ScopeFunc {
 			return quote(tableName) + "." + quote(name)
 		}
 		dialect := q.Connection.Dialect.Name()
-		where, args, order := BuildWhereAndOrder(p.PageToken().Columns(), quoteAndContextualize, dialect)
-		// IMPORTANT: Ensures correct query logic by grouping conditions.
-		// Without parentheses, `WHERE otherCond AND pageCond1 OR pageCond2` would be
-		// evaluated as `(otherCond = ? AND pageCond1) OR pageCond2`, potentially returning
-		// rows that do not match `otherCond`.
-		// We fix it by forcing the query to be: `WHERE otherCond AND (paginationCond1 OR paginationCond2)`.
-		where = "(" + where + ")"
+		token := p.PageToken()
+		build := BuildWhereAndOrderWithDirection
+		if p.rowValues {
+			build = BuildWhereAndOrderRowValues
+		}
+		where, args, order := build(token.Columns(), quoteAndContextualize, dialect, token.Direction())
+		if where != "" {
+			// IMPORTANT: Ensures correct query logic by grouping conditions.
+			// Without parentheses, `WHERE otherCond AND pageCond1 OR pageCond2` would be
+			// evaluated as `(otherCond = ? AND pageCond1) OR pageCond2`, potentially returning
+			// rows that do not match `otherCond`.
+			// We fix it by forcing the query to be: `WHERE otherCond AND (paginationCond1 OR paginationCond2)`.
+			q = q.Where("("+where+")", args...)
+		}
 
 		return q.
-			Where(where, args...).
 			Order(order).
 			Limit(p.Size() + 1)
 	}
 }
 
+// BuildWhereAndOrder builds the keyset predicate selecting the rows after the
+// page token's column values, and the matching ORDER BY clause.
 func BuildWhereAndOrder(columns []Column, quote func(string) string, dialect string) (string, []any, string) {
-	var whereBuilder, orderByBuilder, prevEqual strings.Builder
+	return BuildWhereAndOrderWithDirection(columns, quote, dialect, DirectionForward)
+}
+
+// BuildWhereAndOrderWithDirection is like BuildWhereAndOrder, but for
+// DirectionBackward it selects the rows before the page token's column values
+// and reverses the order. The rows are therefore returned in reverse order;
+// Result and ResultFunc flip them back.
+//
+// NULLs always sort before all other values in ascending order (and after them
+// in descending order), independent of the direction.
+func BuildWhereAndOrderWithDirection(columns []Column, quote func(string) string, dialect string, direction Direction) (string, []any, string) {
+	if direction == DirectionBackward {
+		reversed := make([]Column, len(columns))
+		for i, col := range columns {
+			col.Order = col.Order.reverse()
+			reversed[i] = col
+		}
+		columns = reversed
+	}
+
+	var orderByBuilder strings.Builder
 
 	keysetCols := make([]Column, 0, len(columns))
 
@@ -99,52 +146,98 @@ func BuildWhereAndOrder(columns []Column, quote func(string) string, dialect str
 
 	args := make([]any, 0, len(keysetCols)*(len(keysetCols)+1)/2)
 	prevEqualArgs := make([]any, 0, len(keysetCols))
+	prevEqual := make([]string, 0, len(keysetCols))
+	branches := make([]string, 0, len(keysetCols))
 
-	whereBuilder.WriteRune('(')
-
-	for i, part := range keysetCols {
+	for _, part := range keysetCols {
 		column := quote(part.Name)
 		sign, _ := part.Order.extract()
-
-		if i > 0 {
-			whereBuilder.WriteString(") OR (")
-		}
-
-		whereBuilder.WriteString(prevEqual.String())
-		if prevEqual.Len() > 0 {
-			whereBuilder.WriteString(" AND ")
-		}
-
 		isNull := part.Nullable && isSQLNull(part.Value)
 
-		if !part.Nullable {
-			whereBuilder.WriteString(column + " " + sign + " ?")
-		} else if !isNull {
-			whereBuilder.WriteString(column + " IS NOT NULL AND " + column + " " + sign + " ?")
-		} else {
-			whereBuilder.WriteString(column + " IS NOT NULL")
+		// NULL is the smallest value, see the ORDER BY clause above.
+		var cond string
+		switch {
+		case !part.Nullable:
+			cond = column + " " + sign + " ?"
+		case !isNull && part.Order == OrderAscending:
+			cond = column + " IS NOT NULL AND " + column + " > ?"
+		case !isNull:
+			cond = "(" + column + " IS NULL OR " + column + " < ?)"
+		case part.Order == OrderAscending:
+			cond = column + " IS NOT NULL"
+		default:
+			// Nothing sorts after NULL in descending order.
 		}
 
-		if i > 0 {
-			prevEqual.WriteString(" AND ")
+		if cond != "" {
+			branches = append(branches, strings.Join(append(prevEqual, cond), " AND "))
+			args = append(args, prevEqualArgs...)
+			if !isNull {
+				args = append(args, part.Value)
+			}
 		}
 
-		if !part.Nullable {
-			prevEqual.WriteString(column + " = ?")
-			prevEqualArgs = append(prevEqualArgs, part.Value)
-		} else if !isNull {
-			prevEqual.WriteString(column + " = ?")
+		if !isNull {
+			prevEqual = append(prevEqual, column+" = ?")
 			prevEqualArgs = append(prevEqualArgs, part.Value)
 		} else {
-			prevEqual.WriteString(column + " IS NULL")
+			prevEqual = append(prevEqual, column+" IS NULL")
 		}
+	}
+
+	// All remaining rows sort before the page token.
+	if len(branches) == 0 {
+		return "(1 = 0)", nil, orderByBuilder.String()
+	}
+
+	return "(" + strings.Join(branches, ") OR (") + ")", args, orderByBuilder.String()
+}
 
-		args = append(args, prevEqualArgs...)
+// BuildWhereAndOrderRowValues is like BuildWhereAndOrderWithDirection, but on
+// PostgreSQL and CockroachDB it compares all keyset columns at once using a
+// row-value comparison, e.g. `(a, b) > (?, ?)`, which the query planner can
+// turn into a single range scan over a composite index.
+//
+// Row-value comparisons are only equivalent to the expanded form if all
+// unconstrained columns are non-nullable and share the same order. Otherwise,
+// and on all other dialects, the expanded form is returned.
+func BuildWhereAndOrderRowValues(columns []Column, quote func(string) string, dialect string, direction Direction) (string, []any, string) {
+	where, args, order := BuildWhereAndOrderWithDirection(columns, quote, dialect, direction)
+	if where == "" || (dialect != "postgres" && dialect != "cockroach") {
+		return where, args, order
+	}
+
+	keysetCols := make([]Column, 0, len(columns))
+	for _, col := range columns {
+		if !col.HasConstraint {
+			keysetCols = append(keysetCols, col)
+		}
+	}
+	if len(keysetCols) < 2 {
+		return where, args, order
+	}
+	for _, col := range keysetCols {
+		if col.Nullable || col.Order != keysetCols[0].Order {
+			return where, args, order
+		}
 	}
 
-	whereBuilder.WriteRune(')')
+	sortOrder := keysetCols[0].Order
+	if direction == DirectionBackward {
+		sortOrder = sortOrder.reverse()
+	}
+	sign, _ := sortOrder.extract()
+
+	names := make([]string, len(keysetCols))
+	placeholders := make([]string, len(keysetCols))
+	args = make([]any, len(keysetCols))
+	for i, col := range keysetCols {
+		names[i] = quote(col.Name)
+		placeholders[i] = "?"
+		args[i] = col.Value
+	}
 
-	return whereBuilder.String(), args, orderByBuilder.String()
+	return "(" + strings.Join(names, ", ") + ") " + sign + " (" + strings.Join(placeholders, ", ") + ")", args, order
 }
 
 // isSQLNull reports whether v represents a SQL NULL value.
@@ -0,0 +1,133 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package keysetpagination
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/pop/v6"
+
+	"github.com/ory/x/sqlcon/dockertest"
+)
+
+func benchmarkColumns(n int) []Column {
+	columns := make([]Column, n)
+	for i := range columns {
+		columns[i] = Column{Name: fmt.Sprintf("col_%d", i), Order: OrderAscending, Value: i}
+	}
+	return columns
+}
+
+func benchmarkQuote(name string) string {
+	return `"items"."` + name + `"`
+}
+
+func BenchmarkBuildWhereAndOrder(b *testing.B) {
+	for _, n := range []int{2, 4, 8} {
+		columns := benchmarkColumns(n)
+		for _, dialect := range []string{"postgres", "cockroach", "mysql"} {
+			b.Run(fmt.Sprintf("columns=%d/dialect=%s/expanded", n, dialect), func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					BuildWhereAndOrder(columns, benchmarkQuote, dialect)
+				}
+			})
+			b.Run(fmt.Sprintf("columns=%d/dialect=%s/row_values", n, dialect), func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					BuildWhereAndOrderRowValues(columns, benchmarkQuote, dialect, DirectionForward)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkBuildWhereAndOrderRowValuesFallback(b *testing.B) {
+	// A nullable column prevents the row-value comparison, so the expanded
+	// form is built after checking the columns.
+	columns := benchmarkColumns(4)
+	columns[1].Nullable = true
+
+	b.Run("expanded", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			BuildWhereAndOrder(columns, benchmarkQuote, "postgres")
+		}
+	})
+	b.Run("row_values", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			BuildWhereAndOrderRowValues(columns, benchmarkQuote, "postgres", DirectionForward)
+		}
+	})
+}
+
+// TestRowValuesUseCompositeIndex compares the query plans of the expanded and
+// the row-value predicates on a table with a composite index over the keyset
+// columns. The row-value predicate must be answered by a range scan over the
+// index instead of filtering the rows.
+func TestRowValuesUseCompositeIndex(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test which requires a database in short mode")
+	}
+
+	for name, dsn := range map[string]func(testing.TB) string{
+		"dialect=postgres":  dockertest.RunTestPostgreSQL,
+		"dialect=cockroach": dockertest.RunTestCockroachDB,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			conn := dockertest.ConnectPop(t, dsn(t))
+			t.Cleanup(func() { _ = conn.Close() })
+
+			for _, stmt := range []string{
+				"CREATE TABLE keyset_plans (a INT NOT NULL, b INT NOT NULL, payload TEXT NOT NULL, PRIMARY KEY (b))",
+				"CREATE INDEX keyset_plans_a_b_idx ON keyset_plans (a, b)",
+				"INSERT INTO keyset_plans (a, b, payload) SELECT CAST(floor(i / 100) AS INT), i, 'payload' FROM generate_series(1, 100000) AS i",
+				"ANALYZE keyset_plans",
+			} {
+				require.NoError(t, conn.RawQuery(stmt).Exec(), stmt)
+			}
+
+			columns := []Column{
+				{Name: "a", Order: OrderAscending, Value: 500},
+				{Name: "b", Order: OrderAscending, Value: 50042},
+			}
+			dialect := conn.Dialect.Name()
+
+			where, args, order := BuildWhereAndOrder(columns, conn.Dialect.Quote, dialect)
+			expanded := explain(t, conn, where, order, args)
+			where, args, order = BuildWhereAndOrderRowValues(columns, conn.Dialect.Quote, dialect, DirectionForward)
+			require.Contains(t, where, ") > (", "expected a row-value comparison")
+			rowValues := explain(t, conn, where, order, args)
+
+			t.Logf("expanded predicate:\n%s", expanded)
+			t.Logf("row-value predicate:\n%s", rowValues)
+
+			switch dialect {
+			case "postgres":
+				assert.Contains(t, rowValues, "keyset_plans_a_b_idx")
+				assert.Contains(t, rowValues, "Index Cond: (ROW(a, b) > ROW(")
+				assert.NotContains(t, rowValues, "Filter:")
+			case "cockroach":
+				assert.Contains(t, rowValues, "keyset_plans@keyset_plans_a_b_idx")
+				assert.NotContains(t, rowValues, "FULL SCAN")
+				assert.NotContains(t, rowValues, "• filter")
+			}
+		})
+	}
+}
+
+func explain(t *testing.T, conn *pop.Connection, where, order string, args []any) string {
+	var plan []string
+	query := "EXPLAIN SELECT a, b FROM keyset_plans WHERE " + where + " ORDER BY " + order + " LIMIT 100"
+	require.NoError(t, conn.RawQuery(query, args...).All(&plan), query)
+	return strings.Join(plan, "\n")
+}
@@ -44,7 +44,7 @@ type RequestParameters struct {
 
 // Pagination Response Header
 //
-// The `Link` HTTP header contains multiple links (`first`, `next`) formatted as:
+// The `Link` HTTP header contains multiple links (`first`, `prev`, `next`) formatted as:
 // `<https://{project-slug}.projects.oryapis.com/admin/sessions?page_size=250&page_token=>; rel="first"`
 //
 // For details on pagination please head over to the [pagination documentation](https://www.ory.com/docs/ecosystem/api-design#pagination).
@@ -56,6 +56,7 @@ type ResponseHeaders struct {
 	// The `Link` header contains a comma-delimited list of links to the following pages:
 	//
 	// - first: The first page of results.
+	// - prev: The previous page of results.
 	// - next: The next page of results.
 	//
 	// Pages are omitted if they do not exist. For example, if there is no next page, the `next` link is omitted. Examples:
@@ -63,17 +64,41 @@ type ResponseHeaders struct {
 	//	</admin/sessions?page_size=250&page_token={last_item_uuid}; rel="first",/admin/sessions?page_size=250&page_token=>; rel="next"
 	//
 	Link string `json:"link"`
+
+	// The Total Count
+	//
+	// The number of items matching the request across all pages. Only set if
+	// the endpoint counts its items exactly.
+	TotalCount int64 `json:"x-total-count,omitempty"`
+
+	// The Estimated Total Count
+	//
+	// An estimate of the number of items matching the request across all pages.
+	// Only set if the endpoint estimates its item count.
+	TotalCountEstimate int64 `json:"x-total-count-estimate,omitempty"`
 }
 
 // SetLinkHeader adds the Link header for the page encoded by the paginator.
-// It contains links to the first and next page, if one exists.
+// It contains links to the first page, and to the previous and next page if they exist.
+// If the items were counted with Count, it also sets the X-Total-Count or
+// X-Total-Count-Estimate header.
 func SetLinkHeader(w http.ResponseWriter, keys [][32]byte, u *url.URL, p *Paginator) {
+	SetLinkHeaderWithKeyset(w, NewKeyset(keys), Audience{}, u, p)
+}
+
+// SetLinkHeaderWithKeyset is like SetLinkHeader, but encrypts the page tokens
+// with the keyset's active key and binds them to the audience.
+func SetLinkHeaderWithKeyset(w http.ResponseWriter, ks Keyset, aud Audience, u *url.URL, p *Paginator) {
 	size := p.Size()
-	link := []string{linkPart(u, "first", p.DefaultToken().Encrypt(keys), size)}
+	link := []string{linkPart(u, "first", p.DefaultToken().EncryptWithKeyset(ks, aud), size)}
+	if prev, ok := p.PrevPageToken(); ok {
+		link = append(link, linkPart(u, "prev", prev.EncryptWithKeyset(ks, aud), size))
+	}
 	if !p.isLast {
-		link = append(link, linkPart(u, "next", p.PageToken().Encrypt(keys), size))
+		link = append(link, linkPart(u, "next", p.PageToken().EncryptWithKeyset(ks, aud), size))
 	}
 	w.Header().Set("Link", strings.Join(link, ","))
+	setCountHeader(w, p)
 }
 
 func linkPart(u *url.URL, rel, token string, size int) string {
@@ -86,13 +111,19 @@ func linkPart(u *url.URL, rel, token string, size int) string {
 
 // ParseQueryParams extracts the pagination options from the URL query.
 func ParseQueryParams(keys [][32]byte, q url.Values) ([]Option, error) {
+	return ParseQueryParamsWithKeyset(NewKeyset(keys), Audience{}, q)
+}
+
+// ParseQueryParamsWithKeyset is like ParseQueryParams, but only accepts page
+// tokens issued for the audience.
+func ParseQueryParamsWithKeyset(ks Keyset, aud Audience, q url.Values) ([]Option, error) {
 	var opts []Option
 	if t := cmp.Or(q["page_token"]...); t != "" {
 		raw, err := url.QueryUnescape(t)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
-		token, err := ParsePageToken(keys, raw)
+		token, err := ParsePageTokenWithKeyset(ks, aud, raw)
 		if err != nil {
 			return nil, err
 		}
@@ -109,18 +140,48 @@ func ParseQueryParams(keys [][32]byte, q url.Values) ([]Option, error) {
 }
 
 // ParsePageToken parses a page token from the given raw string using the provided keys.
-// If decryption fails with all provided keys (including when no keys are given), it
-// falls back to using the fallbackEncryptionKey and returns an error if that also fails.
+// The fallbackEncryptionKey is only used if no keys are given. The page token must not
+// be bound to an audience.
 func ParsePageToken(keys [][32]byte, raw string) (t PageToken, err error) {
-	for i := range keys {
-		err = errors.WithStack(t.decrypt(keys[i], raw))
+	return ParsePageTokenWithKeyset(NewKeyset(keys), Audience{}, raw)
+}
+
+// ParsePageTokenWithKeyset parses a page token issued for the audience. The
+// page token is decrypted with the active or decrypt-only key matching its key
+// ID, or with the fallbackEncryptionKey if the keyset is empty.
+func ParsePageTokenWithKeyset(ks Keyset, aud Audience, raw string) (t PageToken, err error) {
+	kid, sealed, ok := strings.Cut(raw, keyIDSeparator)
+	if !ok {
+		// Page tokens issued before key IDs were introduced are not bound to
+		// an audience, so they are rejected where one is expected. Remove
+		// this fallback once all of them have expired.
+		if aud != (Audience{}) {
+			return t, errors.WithStack(ErrInvalidPaginationToken())
+		}
+		return parseUnboundPageToken(ks, raw)
+	}
+
+	for _, key := range ks.decryptionKeys() {
+		if KeyID(key) != kid {
+			continue
+		}
+		err = errors.WithStack(t.decrypt(key, aud, sealed))
 		if errors.Is(err, ErrInvalidPaginationToken()) {
 			continue
 		}
 		// either we successfully decrypted the token, or we got an error that is not ErrInvalidPaginationToken, in both cases we should return immediately
 		return t, err
 	}
-	// as a last resort, try the fallback key
-	err = t.decrypt(fallbackEncryptionKey, raw)
-	return t, errors.WithStack(err)
+	return t, errors.WithStack(ErrInvalidPaginationToken())
+}
+
+func parseUnboundPageToken(ks Keyset, raw string) (t PageToken, err error) {
+	for _, key := range ks.decryptionKeys() {
+		err = errors.WithStack(t.decrypt(key, Audience{}, raw))
+		if errors.Is(err, ErrInvalidPaginationToken()) {
+			continue
+		}
+		return t, err
+	}
+	return t, err
 }
@@ -0,0 +1,227 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package paginationiter
+
+import (
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/peterhellberg/link"
+	"github.com/pkg/errors"
+
+	keysetpagination "github.com/ory/x/pagination/keysetpagination_v2"
+)
+
+const (
+	// DefaultIterateMaxRetries is the number of times a page is re-requested
+	// after a rate-limit response before Iterate gives up.
+	DefaultIterateMaxRetries = 5
+	// DefaultIterateMaxRetryWait caps how long Iterate waits before
+	// re-requesting a rate-limited page.
+	DefaultIterateMaxRetryWait = time.Minute
+)
+
+type (
+	// IterateOption configures Iterate.
+	IterateOption func(*iterateOptions)
+
+	iterateOptions struct {
+		prefetch     bool
+		maxRetries   int
+		maxRetryWait time.Duration
+	}
+
+	page[T any] struct {
+		items []T
+		next  *url.URL
+		err   error
+	}
+)
+
+// WithPrefetch fetches the next page concurrently while the items of the
+// current page are consumed.
+func WithPrefetch() IterateOption {
+	return func(o *iterateOptions) { o.prefetch = true }
+}
+
+// WithMaxRetries sets how often a page is re-requested after the server
+// responded with 429 Too Many Requests or 503 Service Unavailable.
+func WithMaxRetries(n int) IterateOption {
+	return func(o *iterateOptions) { o.maxRetries = max(0, n) }
+}
+
+// WithMaxRetryWait caps the time to wait before re-requesting a rate-limited
+// page, regardless of the Retry-After header.
+func WithMaxRetryWait(d time.Duration) IterateOption {
+	return func(o *iterateOptions) { o.maxRetryWait = d }
+}
+
+// Iterate returns an iterator over all items of a paginated list endpoint. It
+// sends req, decodes the items of each page with decode, and follows the
+// rel="next" Link header, which is set by all pagination styles in this
+// repository (page, token, and keyset pagination). Iteration stops once a page
+// has no next link or no items. Next links to another origin are rejected, so
+// that the credentials of req are not leaked.
+//
+// Rate-limited pages are re-requested after the duration in the Retry-After
+// header. Any other error, including non-2xx responses, is yielded once and
+// ends the iteration. The request must not have a body.
+//
+// Usage:
+//
+//	for item, err := range paginationiter.Iterate(ctx, client, req, decodeIdentities) {
+//		if err != nil {
+//			return err
+//		}
+//		// ...
+//	}
+func Iterate[T any](ctx context.Context, client *http.Client, req *http.Request, decode func(*http.Response) ([]T, error), opts ...IterateOption) iter.Seq2[T, error] {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	o := &iterateOptions{
+		maxRetries:   DefaultIterateMaxRetries,
+		maxRetryWait: DefaultIterateMaxRetryWait,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(yield func(T, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var (
+			zero    T
+			next    = req.URL
+			pending <-chan page[T]
+			seen    = make(map[string]struct{})
+		)
+		for next != nil {
+			seen[next.String()] = struct{}{}
+
+			var p page[T]
+			if pending != nil {
+				p = <-pending
+				pending = nil
+			} else {
+				p = fetchPage(ctx, client, req, next, decode, o)
+			}
+			if p.err != nil {
+				yield(zero, p.err)
+				return
+			}
+
+			next = p.next
+			if next != nil {
+				// Guards against servers which link to the same page
+				// again, or which keep emitting a next link after the last
+				// item.
+				if _, ok := seen[next.String()]; ok || len(p.items) == 0 {
+					next = nil
+				}
+			}
+			if o.prefetch && next != nil {
+				ch := make(chan page[T], 1)
+				go func(u *url.URL) { ch <- fetchPage(ctx, client, req, u, decode, o) }(next)
+				pending = ch
+			}
+
+			for _, item := range p.items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func fetchPage[T any](ctx context.Context, client *http.Client, req *http.Request, u *url.URL, decode func(*http.Response) ([]T, error), o *iterateOptions) page[T] {
+	r := req.Clone(ctx)
+	r.URL = u
+	if u != req.URL {
+		r.Host = ""
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := client.Do(r)
+		if err != nil {
+			return page[T]{err: errors.WithStack(err)}
+		}
+
+		if (res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable) && attempt < o.maxRetries {
+			wait := min(retryAfter(res.Header, attempt), o.maxRetryWait)
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+
+			select {
+			case <-ctx.Done():
+				return page[T]{err: errors.WithStack(ctx.Err())}
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		return decodePage(res, decode)
+	}
+}
+
+func decodePage[T any](res *http.Response, decode func(*http.Response) ([]T, error)) page[T] {
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return page[T]{err: errors.Errorf("unable to fetch page %s: unexpected status code %d", res.Request.URL, res.StatusCode)}
+	}
+
+	items, err := decode(res)
+	if err != nil {
+		return page[T]{err: err}
+	}
+
+	p := page[T]{items: items}
+	current := res.Request.URL
+	if _, _, token, isLast := keysetpagination.ParseHeader(res); !isLast {
+		// Token and keyset pagination: request the same URL with the next
+		// page token.
+		next := *current
+		q := next.Query()
+		q.Set("page_token", token)
+		next.RawQuery = q.Encode()
+		p.next = &next
+	} else if l, ok := link.ParseResponse(res)["next"]; ok {
+		// Page pagination links carry page numbers or offsets instead.
+		next, err := url.Parse(l.URI)
+		if err != nil {
+			return page[T]{err: errors.WithStack(err)}
+		}
+		p.next = current.ResolveReference(next)
+	}
+
+	// The request, including its credentials, is sent to the next page, so
+	// it must not leave the origin.
+	if p.next != nil && (p.next.Scheme != current.Scheme || p.next.Host != current.Host) {
+		return page[T]{err: errors.Errorf("refusing to follow the next page link to %s://%s, which has a different origin than %s://%s", p.next.Scheme, p.next.Host, current.Scheme, current.Host)}
+	}
+	return p
+}
+
+// retryAfter returns the duration to wait according to the Retry-After
+// header, which is either a number of seconds or an HTTP date. Without the
+// header, it backs off exponentially starting at one second.
+func retryAfter(h http.Header, attempt int) time.Duration {
+	if v := h.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return max(0, time.Until(t))
+		}
+	}
+	return time.Second << min(attempt, 6)
+}
@@ -4,6 +4,10 @@
 package paginationplanner
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/pkg/errors"
 
 	keysetpagination "github.com/ory/x/pagination/keysetpagination_v2"
@@ -43,12 +47,18 @@ func NewPaginationPlanner(fallbackPlan PaginationPlan, plans []PaginationPlan) (
 // Eligibility requires an exact ColumnSet match and (if set) Condition match.
 // If none match, the FallbackPlan is used for building the Paginator.
 func (p *PaginationPlanner) GetPaginator(q Query, pageOpts ...keysetpagination.Option) (*keysetpagination.Paginator, error) {
+	paginator, _, err := p.GetPaginatorWithExplanation(q, pageOpts...)
+	return paginator, err
+}
+
+// GetPaginatorWithExplanation is like GetPaginator, but also explains which plan was chosen and why.
+func (p *PaginationPlanner) GetPaginatorWithExplanation(q Query, pageOpts ...keysetpagination.Option) (*keysetpagination.Paginator, Explanation, error) {
+	plan, explanation := p.pickPlan(q)
 	if len(q) == 0 {
-		return keysetpagination.NewPaginator(append(pageOpts, keysetpagination.WithDefaultToken(p.FallbackPlan.DefaultPageToken))...)
+		paginator, err := keysetpagination.NewPaginator(append(pageOpts, keysetpagination.WithDefaultToken(p.FallbackPlan.DefaultPageToken))...)
+		return paginator, explanation, err
 	}
 
-	plan := p.pickPlan(q)
-
 	origCols := plan.DefaultPageToken.Columns()
 
 	// Make a fresh copy so we don't mutate the original backing array.
@@ -62,23 +72,86 @@ func (p *PaginationPlanner) GetPaginator(q Query, pageOpts ...keysetpagination.O
 	}
 
 	defaultToken := keysetpagination.WithDefaultToken(keysetpagination.NewPageToken(cols...))
-	return keysetpagination.NewPaginator(append(pageOpts, defaultToken)...)
+	paginator, err := keysetpagination.NewPaginator(append(pageOpts, defaultToken)...)
+	return paginator, explanation, err
 }
 
-func (p *PaginationPlanner) pickPlan(q Query) PaginationPlan {
+// Explain returns which plan GetPaginator picks for the query and why the other plans were rejected.
+func (p *PaginationPlanner) Explain(q Query) Explanation {
+	_, explanation := p.pickPlan(q)
+	return explanation
+}
+
+func (p *PaginationPlanner) pickPlan(q Query) (PaginationPlan, Explanation) {
+	explanation := Explanation{ConstrainedColumns: q.constrainedColNames()}
+	if len(q) == 0 {
+		explanation.Plan, explanation.IsFallback = p.FallbackPlan.Name, true
+		return p.FallbackPlan, explanation
+	}
+
 	constrainedCols := q.constrainedCols()
 
-	for _, plan := range p.Plans {
-		if _, ok := plan.applicableQueries[constrainedCols]; !ok {
-			continue
+	var picked *PaginationPlan
+	for i := range p.Plans {
+		plan := &p.Plans[i]
+		decision := PlanDecision{Plan: plan.Name}
+		switch {
+		case picked != nil:
+			decision.Reason = fmt.Sprintf("not evaluated, plan %q was picked before", picked.Name)
+		case !plan.isApplicable(constrainedCols):
+			decision.Reason = "constrained columns do not match any applicable query"
+		case plan.Condition != nil && !plan.Condition(q):
+			decision.Reason = "condition not met"
+		default:
+			decision.Picked, decision.Reason = true, "constrained columns match an applicable query"
+			picked = plan
 		}
-		if plan.Condition != nil && !plan.Condition(q) {
+		explanation.Decisions = append(explanation.Decisions, decision)
+	}
+
+	if picked == nil {
+		explanation.Plan, explanation.IsFallback = p.FallbackPlan.Name, true
+		return p.FallbackPlan, explanation
+	}
+	explanation.Plan = picked.Name
+	return *picked, explanation
+}
+
+// Explanation describes which plan the planner picked for a query and why.
+type Explanation struct {
+	// Plan is the name of the picked plan.
+	Plan string `json:"plan"`
+
+	// IsFallback is true if no plan matched and the FallbackPlan was used.
+	IsFallback bool `json:"is_fallback"`
+
+	// ConstrainedColumns are the names of the query's constrained columns, in alphabetical order.
+	ConstrainedColumns []string `json:"constrained_columns"`
+
+	// Decisions contains one entry per plan, in the order the plans were evaluated.
+	Decisions []PlanDecision `json:"decisions"`
+}
+
+// PlanDecision describes why a plan was picked or rejected.
+type PlanDecision struct {
+	Plan   string `json:"plan"`
+	Picked bool   `json:"picked"`
+	Reason string `json:"reason"`
+}
+
+func (e Explanation) String() string {
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "picked plan %q for constrained columns [%s]", e.Plan, strings.Join(e.ConstrainedColumns, ", "))
+	if e.IsFallback {
+		b.WriteString(" (fallback)")
+	}
+	for _, d := range e.Decisions {
+		if d.Picked {
 			continue
 		}
-		return plan
+		_, _ = fmt.Fprintf(&b, "; rejected %q: %s", d.Plan, d.Reason)
 	}
-
-	return p.FallbackPlan
+	return b.String()
 }
 
 type PaginationPlan struct {
@@ -96,6 +169,11 @@ type PaginationPlan struct {
 	Condition func(q Query) bool
 }
 
+func (pp *PaginationPlan) isApplicable(constrainedCols uint) bool {
+	_, ok := pp.applicableQueries[constrainedCols]
+	return ok
+}
+
 func (pp *PaginationPlan) populateInternals() {
 	pp.applicableQueries = make(map[uint]struct{}, len(pp.ApplicableQueries))
 	for _, cols := range pp.ApplicableQueries {
@@ -185,6 +263,17 @@ func (qc Query) colByName(name string) (ColumnConstraint, bool) {
 	return colUnconstrained, false
 }
 
+func (qc Query) constrainedColNames() []string {
+	names := make([]string, 0, len(qc))
+	for col, state := range qc {
+		if state.IsConstrained() {
+			names = append(names, col.name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
 func (qc Query) constrainedCols() uint {
 	var cols uint
 	for col, state := range qc {
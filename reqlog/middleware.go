@@ -11,6 +11,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/negroni"
 
+	"github.com/ory/x/httpx"
 	"github.com/ory/x/logrusx"
 )
 
@@ -40,6 +41,8 @@ type Middleware struct {
 
 	logStarting bool
 
+	clientIPResolver *httpx.ClientIPResolver
+
 	clock timer
 
 	logLevel logrus.Level
@@ -92,6 +95,12 @@ func (m *Middleware) SetLogStarting(v bool) {
 	m.logStarting = v
 }
 
+// SetClientIPResolver sets the resolver used to determine the client IP which
+// is passed to Before. Without a resolver, the X-Real-IP header is trusted.
+func (m *Middleware) SetClientIPResolver(r *httpx.ClientIPResolver) {
+	m.clientIPResolver = r
+}
+
 // ExcludePaths adds new URL paths to be ignored during logging. The URL u is parsed, hence the returned error
 func (m *Middleware) ExcludePaths(paths ...string) *Middleware {
 	for _, path := range paths {
@@ -134,7 +143,9 @@ func (m *Middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next htt
 
 	// Try to get the real IP
 	remoteAddr := r.RemoteAddr
-	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+	if m.clientIPResolver != nil {
+		remoteAddr = m.clientIPResolver.ClientIP(r)
+	} else if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
 		remoteAddr = realIP
 	}
 
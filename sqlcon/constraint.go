@@ -0,0 +1,162 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sqlcon
+
+import (
+	stderrs "errors"
+	"regexp"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+
+	"github.com/ory/herodot"
+)
+
+// DetailConstraintViolation is the key of the herodot error detail which
+// holds the ConstraintViolation.
+const DetailConstraintViolation = "constraint_violation"
+
+// ConstraintViolation describes which constraint a statement violated. Fields
+// which the database does not report are left empty.
+type ConstraintViolation struct {
+	Table      string   `json:"table,omitempty"`
+	Constraint string   `json:"constraint,omitempty"`
+	Columns    []string `json:"columns,omitempty"`
+}
+
+var (
+	// Key (email, nid)=(foo@ory.sh, 0c2b...) already exists.
+	postgresKeyDetail = regexp.MustCompile(`^Key \((.+?)\)=`)
+	// duplicate key value violates unique constraint "identities_email_idx"
+	postgresConstraint = regexp.MustCompile(`constraint "([^"]+)"`)
+	// Duplicate entry 'foo@ory.sh' for key 'identities.identities_email_idx'
+	mysqlDuplicateEntry = regexp.MustCompile(`for key '([^']+)'$`)
+	// Cannot add or update a child row: a foreign key constraint fails (`db`.`child`, CONSTRAINT `fk` FOREIGN KEY (`parent_id`) REFERENCES ...
+	mysqlForeignKey = regexp.MustCompile("\\(`[^`]+`\\.`([^`]+)`, CONSTRAINT `([^`]+)` FOREIGN KEY \\(([^)]+)\\)")
+	// UNIQUE constraint failed: identities.email, identities.nid
+	sqliteUnique = regexp.MustCompile(`UNIQUE constraint failed: ([^()]+)`)
+)
+
+// ParseConstraintViolation extracts which constraint a unique or foreign key
+// violation violated. It accepts both driver errors and errors returned by
+// HandleError.
+func ParseConstraintViolation(err error) (*ConstraintViolation, bool) {
+	if err == nil {
+		return nil, false
+	}
+
+	if e, ok := stderrs.AsType[*pgconn.PgError](err); ok {
+		return parsePostgresViolation(e.Code, e.TableName, e.ConstraintName, e.ColumnName, e.Message, e.Detail)
+	}
+
+	type stater = interface {
+		error
+		SQLState() string
+	}
+
+	if e, ok := stderrs.AsType[stater](err); ok {
+		return parsePostgresViolation(e.SQLState(), "", "", "", e.Error(), "")
+	}
+	if e, ok := stderrs.AsType[*mysql.MySQLError](err); ok {
+		return parseMySQLViolation(e)
+	}
+	if e, ok := stderrs.AsType[*sqlite.Error](err); ok {
+		return parseSqliteViolation(e)
+	}
+	return nil, false
+}
+
+func parsePostgresViolation(code, table, constraint, column, message, detail string) (*ConstraintViolation, bool) {
+	switch code {
+	case "23505", // "unique_violation"
+		"23503": // "foreign_key_violation"
+	default:
+		return nil, false
+	}
+
+	v := &ConstraintViolation{Table: table, Constraint: constraint}
+	if v.Constraint == "" {
+		if m := postgresConstraint.FindStringSubmatch(message); m != nil {
+			v.Constraint = m[1]
+		}
+	}
+	if column != "" {
+		v.Columns = []string{column}
+	} else if m := postgresKeyDetail.FindStringSubmatch(detail); m != nil {
+		v.Columns = splitColumns(m[1], "")
+	}
+	return v, true
+}
+
+func parseMySQLViolation(e *mysql.MySQLError) (*ConstraintViolation, bool) {
+	switch e.Number {
+	case 1062: // https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_dup_entry
+		m := mysqlDuplicateEntry.FindStringSubmatch(e.Message)
+		if m == nil {
+			return &ConstraintViolation{}, true
+		}
+		// Since MySQL 8.0.19, the key is qualified with the table name.
+		if table, key, ok := strings.Cut(m[1], "."); ok {
+			return &ConstraintViolation{Table: table, Constraint: key}, true
+		}
+		return &ConstraintViolation{Constraint: m[1]}, true
+	case 1451, // https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_row_is_referenced_2
+		1452: // https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_no_referenced_row_2
+		m := mysqlForeignKey.FindStringSubmatch(e.Message)
+		if m == nil {
+			return &ConstraintViolation{}, true
+		}
+		return &ConstraintViolation{Table: m[1], Constraint: m[2], Columns: splitColumns(m[3], "`")}, true
+	}
+	return nil, false
+}
+
+func parseSqliteViolation(e *sqlite.Error) (*ConstraintViolation, bool) {
+	if isSqliteForeignKeyViolation(e) {
+		// SQLite does not report which foreign key was violated.
+		return &ConstraintViolation{}, true
+	}
+
+	m := sqliteUnique.FindStringSubmatch(e.Error())
+	if m == nil {
+		return nil, false
+	}
+
+	target := strings.TrimSpace(m[1])
+	if index, ok := strings.CutPrefix(target, "index "); ok {
+		return &ConstraintViolation{Constraint: strings.Trim(index, "'")}, true
+	}
+
+	v := new(ConstraintViolation)
+	for _, c := range splitColumns(target, "") {
+		// Columns are qualified with the table name.
+		table, column, ok := strings.Cut(c, ".")
+		if !ok {
+			v.Columns = append(v.Columns, c)
+			continue
+		}
+		v.Table = table
+		v.Columns = append(v.Columns, column)
+	}
+	return v, true
+}
+
+func splitColumns(list, quote string) []string {
+	columns := strings.Split(list, ",")
+	for i, c := range columns {
+		columns[i] = strings.Trim(strings.TrimSpace(c), quote+`"`)
+	}
+	return columns
+}
+
+// withConstraintViolation attaches the violated constraint, if known, to the
+// error's details.
+func withConstraintViolation(e *herodot.DefaultError, err error) *herodot.DefaultError {
+	if v, ok := ParseConstraintViolation(err); ok {
+		return e.WithDetail(DetailConstraintViolation, v)
+	}
+	return e
+}
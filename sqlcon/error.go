@@ -68,7 +68,7 @@ func ErrNoSuchColumn() *herodot.DefaultError {
 func handlePostgres(err error, sqlState string) error {
 	switch sqlState {
 	case "23505": // "unique_violation"
-		return errors.WithStack(ErrUniqueViolation().WithWrap(err))
+		return errors.WithStack(withConstraintViolation(ErrUniqueViolation().WithWrap(err), err))
 	case "40001", // "serialization_failure" in CRDB
 		"CR000": // "serialization_failure"
 		return errors.WithStack(ErrConcurrentUpdate().WithWrap(err))
@@ -103,7 +103,7 @@ func HandleError(err error) error {
 	if e, ok := stderrs.AsType[*mysql.MySQLError](err); ok {
 		switch e.Number {
 		case 1062: // https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_dup_entry
-			return errors.WithStack(ErrUniqueViolation().WithWrap(err))
+			return errors.WithStack(withConstraintViolation(ErrUniqueViolation().WithWrap(err), err))
 		case 1146: // https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_no_such_table
 			return errors.WithStack(ErrNoSuchTable().WithWrap(e))
 		case 1054: // https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_bad_field_error
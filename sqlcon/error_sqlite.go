@@ -25,7 +25,7 @@ func handleSqlite(e *sqlite.Error, err error) error {
 	switch e.Code() {
 	case sqlite3lib.SQLITE_CONSTRAINT_UNIQUE,
 		sqlite3lib.SQLITE_CONSTRAINT_PRIMARYKEY:
-		return errors.WithStack(ErrUniqueViolation().WithWrap(err))
+		return errors.WithStack(withConstraintViolation(ErrUniqueViolation().WithWrap(err), err))
 	case sqlite3lib.SQLITE_ERROR:
 		if strings.Contains(e.Error(), "no such table") {
 			return errors.WithStack(ErrNoSuchTable().WithWrap(err))
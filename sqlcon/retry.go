@@ -0,0 +1,171 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sqlcon
+
+import (
+	"context"
+	"database/sql/driver"
+	stderrs "errors"
+	"math/rand/v2"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"modernc.org/sqlite"
+	sqlite3lib "modernc.org/sqlite/lib"
+)
+
+// RetryPolicy configures Retry. Zero fields fall back to the values of
+// DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times fn is called at most, including the
+	// first call.
+	MaxAttempts int
+	// InitialInterval is the backoff before the first retry.
+	InitialInterval time.Duration
+	// MaxInterval caps the backoff between two attempts.
+	MaxInterval time.Duration
+	// Multiplier is the factor by which the backoff grows per attempt.
+	Multiplier float64
+}
+
+// DefaultRetryPolicy returns the policy used for zero fields of a RetryPolicy.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     10,
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      2,
+	}
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	d := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = d.MaxAttempts
+	}
+	if p.InitialInterval <= 0 {
+		p.InitialInterval = d.InitialInterval
+	}
+	if p.MaxInterval <= 0 {
+		p.MaxInterval = d.MaxInterval
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = d.Multiplier
+	}
+	return p
+}
+
+// Retry calls fn until it succeeds, returns an error which is not retryable
+// according to IsRetryable, the policy's attempts are exhausted, or the context
+// is done. Attempts are separated by an exponential backoff with jitter, and
+// every failed attempt is recorded as an event on the span in ctx.
+//
+// fn must be safe to call repeatedly, i.e. it should run a whole transaction
+// rather than a single statement of one.
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	policy = policy.withDefaults()
+	span := trace.SpanFromContext(ctx)
+
+	interval := policy.InitialInterval
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		retryable := IsRetryable(err)
+		span.AddEvent("sqlcon.retry.attempt_failed", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.Bool("retryable", retryable),
+			attribute.String("error", err.Error()),
+		))
+		if !retryable || attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		// Equal jitter, i.e. a random wait between half and the whole
+		// interval, avoids that conflicting transactions retry in lockstep.
+		wait := interval/2 + rand.N(interval/2+1) //nolint:gosec // jitter does not need a secure source
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(stderrs.Join(err, ctx.Err()))
+		case <-time.After(wait):
+		}
+		interval = min(time.Duration(float64(interval)*policy.Multiplier), policy.MaxInterval)
+	}
+}
+
+// IsRetryable reports whether err is a transient error after which the failed
+// transaction may succeed when retried: serialization failures and deadlocks on
+// all dialects, CockroachDB transaction restarts, lock wait timeouts on MySQL,
+// busy or locked databases on SQLite, and dropped connections.
+func IsRetryable(err error) bool {
+	if err == nil || stderrs.Is(err, context.Canceled) || stderrs.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if stderrs.Is(err, ErrConcurrentUpdate()) {
+		return true
+	}
+
+	type stater = interface {
+		error
+		SQLState() string
+	}
+
+	if e, ok := stderrs.AsType[stater](err); ok && isRetryableSQLState(e.SQLState(), e.Error()) {
+		return true
+	}
+	if e, ok := stderrs.AsType[*pgconn.PgError](err); ok && isRetryableSQLState(e.Code, e.Message) {
+		return true
+	}
+	if e, ok := stderrs.AsType[*mysql.MySQLError](err); ok {
+		switch e.Number {
+		case 1213, // https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_lock_deadlock
+			1205: // https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html#error_er_lock_wait_timeout
+			return true
+		}
+	}
+	if e, ok := stderrs.AsType[*sqlite.Error](err); ok {
+		switch e.Code() {
+		case sqlite3lib.SQLITE_LOCKED,
+			sqlite3lib.SQLITE_BUSY,
+			sqlite3lib.SQLITE_BUSY_RECOVERY,
+			sqlite3lib.SQLITE_BUSY_SNAPSHOT,
+			sqlite3lib.SQLITE_BUSY_TIMEOUT:
+			return true
+		}
+	}
+
+	return isConnectionReset(err)
+}
+
+func isRetryableSQLState(code, message string) bool {
+	switch code {
+	case "40001", // "serialization_failure", also used by CockroachDB for transaction restarts
+		"40P01", // "deadlock_detected"
+		"CR000", // "serialization_failure" in older CockroachDB versions
+		"57P01": // "admin_shutdown", e.g. when a CockroachDB node is drained
+		return true
+	}
+	// Class 08 - Connection Exception
+	if strings.HasPrefix(code, "08") {
+		return true
+	}
+	return strings.Contains(message, "restart transaction")
+}
+
+func isConnectionReset(err error) bool {
+	return stderrs.Is(err, driver.ErrBadConn) ||
+		stderrs.Is(err, mysql.ErrInvalidConn) ||
+		stderrs.Is(err, syscall.ECONNRESET) ||
+		stderrs.Is(err, syscall.EPIPE) ||
+		pgconn.SafeToRetry(err)
+}
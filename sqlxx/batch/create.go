@@ -7,7 +7,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"iter"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -169,7 +171,9 @@ func buildInsertQueryValues[T any](dialect string, mapper *reflectx.Mapper, colu
 }
 
 type createOptions struct {
-	onConflict string
+	onConflict    string
+	keyColumns    []string
+	updateColumns []string
 }
 
 type option func(*createOptions)
@@ -191,6 +195,7 @@ func CreateFromSlice[T any](ctx context.Context, p *TracerConnection, models []T
 }
 
 // Create batch-inserts the given models into the database using a single INSERT statement.
+// If the models exceed the dialect's placeholder limit, they are inserted in chunks.
 // The models are either all created or none.
 func Create[T any](ctx context.Context, p *TracerConnection, models []*T, opts ...option) (err error) {
 	ctx, span := p.Tracer.Tracer().Start(ctx, "persistence.sql.batch.Create")
@@ -205,10 +210,20 @@ func Create[T any](ctx context.Context, p *TracerConnection, models []*T, opts .
 		opt(options)
 	}
 
+	for chunk := range chunks(ctx, p.Connection, models) {
+		if err := insert(ctx, p.Connection, chunk, options.onConflict); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insert inserts the models using a single INSERT statement with the given
+// conflict clause and hydrates their IDs.
+func insert[T any](ctx context.Context, conn *pop.Connection, models []*T, conflictClause string) error {
 	var v T
 	model := pop.NewModel(v, ctx)
 
-	conn := p.Connection
 	quoter, ok := conn.Dialect.(quoter)
 	if !ok {
 		return errors.Errorf("store is not a quoter: %T", conn.Store)
@@ -231,7 +246,7 @@ func Create[T any](ctx context.Context, p *TracerConnection, models []*T, opts .
 		queryArgs.TableName,
 		queryArgs.ColumnsDecl,
 		queryArgs.Placeholders,
-		options.onConflict,
+		conflictClause,
 		returningClause,
 	))
 
@@ -259,6 +274,27 @@ func Create[T any](ctx context.Context, p *TracerConnection, models []*T, opts .
 	return sqlcon.HandleError(err)
 }
 
+// maxPlaceholders returns the maximum number of placeholders the dialect
+// supports in a single statement.
+func maxPlaceholders(dialect string) int {
+	switch dialect {
+	case dbal.DriverPostgreSQL, dbal.DriverCockroachDB, dbal.DriverMySQL:
+		// The wire protocols encode the number of parameters as uint16.
+		return 65535
+	default:
+		// SQLITE_MAX_VARIABLE_NUMBER defaults to 32766 since SQLite 3.32.0.
+		return 32766
+	}
+}
+
+// chunks splits the models into chunks which stay within the dialect's
+// placeholder limit when each model is bound as one row of its columns.
+func chunks[T any](ctx context.Context, conn *pop.Connection, models []*T) iter.Seq[[]*T] {
+	var v T
+	columns := max(1, len(pop.NewModel(v, ctx).Columns().Cols))
+	return slices.Chunk(models, max(1, maxPlaceholders(conn.Dialect.Name())/columns))
+}
+
 // setModelID was copy & pasted from pop. It basically sets
 // the primary key to the given value read from the SQL row.
 func setModelID(row *sql.Rows, model *pop.Model) error {
@@ -0,0 +1,207 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package batch
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx/reflectx"
+	"github.com/pkg/errors"
+
+	"github.com/ory/pop/v6"
+
+	"github.com/ory/x/dbal"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+)
+
+// UpdateFromSlice is a helper around Update that accepts a slice of models
+// instead of a slice of model pointers.
+func UpdateFromSlice[T any](ctx context.Context, p *TracerConnection, models []T, opts ...option) (err error) {
+	var ptrs []*T
+	for k := range models {
+		ptrs = append(ptrs, &models[k])
+	}
+	return Update(ctx, p, ptrs, opts...)
+}
+
+// Update batch-updates the rows matching the key columns of the given models
+// with a single UPDATE statement. It uses UPDATE ... FROM (VALUES ...) on
+// PostgreSQL, CockroachDB, and SQLite, and a join with a derived table on
+// MySQL. If the models exceed the dialect's placeholder limit, they are
+// updated in chunks.
+//
+// Models without a matching row are ignored. By default, rows are matched by
+// their primary key and nid, so rows of other networks are never updated. If updated_at is one of the
+// update columns, which it is by default, it is set to the current time. If
+// UpdateColumns is set without updated_at, the column is left unchanged.
+func Update[T any](ctx context.Context, p *TracerConnection, models []*T, opts ...option) (err error) {
+	ctx, span := p.Tracer.Tracer().Start(ctx, "persistence.sql.batch.Update")
+	defer otelx.End(span, &err)
+
+	if len(models) == 0 {
+		return nil
+	}
+
+	options := &createOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	conn := p.Connection
+	quoter, ok := conn.Dialect.(quoter)
+	if !ok {
+		return errors.Errorf("store is not a quoter: %T", conn.Store)
+	}
+
+	var v T
+	model := pop.NewModel(v, ctx)
+	keys, updates, err := keyAndUpdateColumns(model, options)
+	if err != nil {
+		return err
+	}
+	columns := append(slices.Clone(keys), updates...)
+
+	var types []string
+	if d := conn.Dialect.Name(); d == dbal.DriverPostgreSQL || d == dbal.DriverCockroachDB {
+		if types, err = columnTypes(ctx, conn, quoter, model.TableName(), columns); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for chunk := range slices.Chunk(models, max(1, maxPlaceholders(conn.Dialect.Name())/len(columns))) {
+		query := conn.Dialect.TranslateSQL(buildUpdateQuery(conn.Dialect.Name(), quoter, model.TableName(), keys, updates, types, len(chunk)))
+		values := buildUpdateQueryValues(conn.TX.Mapper, columns, chunk, now)
+		if _, err := conn.TX.ExecContext(ctx, query, values...); err != nil {
+			return sqlcon.HandleError(err)
+		}
+	}
+	return nil
+}
+
+// buildUpdateQuery generates the UPDATE statement for the given number of
+// rows. On PostgreSQL and CockroachDB, the placeholders of the first row are
+// cast to the column types, because the types of placeholders in a VALUES list
+// can not be inferred from the table.
+func buildUpdateQuery(dialect string, quoter quoter, table string, keys, updates, types []string, rows int) string {
+	columns := append(slices.Clone(keys), updates...)
+
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoter.Quote(c)
+	}
+
+	on := make([]string, len(keys))
+	for i, c := range keys {
+		on[i] = fmt.Sprintf("t.%s = v.%s", quoter.Quote(c), quoter.Quote(c))
+	}
+
+	if dialect == dbal.DriverMySQL {
+		selects := make([]string, rows)
+		for i := range selects {
+			pl := make([]string, len(columns))
+			for k := range columns {
+				pl[k] = "?"
+				if i == 0 {
+					pl[k] += " AS " + quoted[k]
+				}
+			}
+			selects[i] = "SELECT " + strings.Join(pl, ", ")
+		}
+
+		set := make([]string, len(updates))
+		for i, c := range updates {
+			set[i] = fmt.Sprintf("t.%s = v.%s", quoter.Quote(c), quoter.Quote(c))
+		}
+
+		return fmt.Sprintf(
+			"UPDATE %s AS t JOIN (\n%s\n) AS v ON %s SET %s",
+			quoter.Quote(table),
+			strings.Join(selects, "\nUNION ALL "),
+			strings.Join(on, " AND "),
+			strings.Join(set, ", "),
+		)
+	}
+
+	placeholders := make([]string, rows)
+	for i := range placeholders {
+		pl := make([]string, len(columns))
+		for k := range columns {
+			pl[k] = "?"
+			if i == 0 && k < len(types) && types[k] != "" {
+				pl[k] = fmt.Sprintf("CAST(? AS %s)", types[k])
+			}
+		}
+		placeholders[i] = fmt.Sprintf("(%s)", strings.Join(pl, ", "))
+	}
+
+	set := make([]string, len(updates))
+	for i, c := range updates {
+		set[i] = fmt.Sprintf("%s = v.%s", quoter.Quote(c), quoter.Quote(c))
+	}
+
+	return fmt.Sprintf(
+		"WITH v (%s) AS (VALUES\n%s\n)\nUPDATE %s AS t SET %s FROM v WHERE %s",
+		strings.Join(quoted, ", "),
+		strings.Join(placeholders, ",\n"),
+		quoter.Quote(table),
+		strings.Join(set, ", "),
+		strings.Join(on, " AND "),
+	)
+}
+
+func buildUpdateQueryValues[T any](mapper *reflectx.Mapper, columns []string, models []*T, now time.Time) (values []any) {
+	for _, m := range models {
+		m := reflect.ValueOf(m)
+		for _, c := range columns {
+			field := mapper.FieldByName(m, c)
+			if c == "updated_at" {
+				field.Set(reflect.ValueOf(now))
+			}
+			values = append(values, field.Interface())
+		}
+	}
+	return values
+}
+
+// columnTypes returns the database types of the given columns, or an empty
+// string for types which can not be used in a cast.
+func columnTypes(ctx context.Context, conn *pop.Connection, quoter quoter, table string, columns []string) ([]string, error) {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoter.Quote(c)
+	}
+
+	rows, err := conn.TX.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s LIMIT 0", strings.Join(quoted, ", "), quoter.Quote(table)))
+	if err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+	defer rows.Close()
+
+	cts, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	types := make([]string, len(cts))
+	for i, ct := range cts {
+		name := ct.DatabaseTypeName()
+		if _, err := strconv.Atoi(name); err == nil {
+			// The driver does not know the type and returned its OID.
+			continue
+		}
+		if elem, ok := strings.CutPrefix(name, "_"); ok {
+			name = elem + "[]"
+		}
+		types[i] = name
+	}
+	return types, sqlcon.HandleError(rows.Err())
+}
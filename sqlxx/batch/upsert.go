@@ -0,0 +1,164 @@
+// Copyright © 2026 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package batch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/pop/v6"
+
+	"github.com/ory/x/dbal"
+	"github.com/ory/x/otelx"
+)
+
+// KeyColumns sets the columns which identify a row: the conflict target of
+// Upsert and the columns Update matches rows by. Defaults to the primary key
+// and, if the model has one, the nid column, so that rows of other networks are
+// never matched.
+//
+// MySQL does not support a conflict target, so Upsert updates the row on a
+// conflict with any unique index.
+func KeyColumns(columns ...string) func(*createOptions) {
+	return func(o *createOptions) {
+		o.keyColumns = columns
+	}
+}
+
+// UpdateColumns sets the columns which are overwritten by Upsert and Update.
+// Defaults to all columns except the key columns, the primary key, nid, and
+// created_at.
+func UpdateColumns(columns ...string) func(*createOptions) {
+	return func(o *createOptions) {
+		o.updateColumns = columns
+	}
+}
+
+// UpsertFromSlice is a helper around Upsert that accepts a slice of models
+// instead of a slice of model pointers.
+func UpsertFromSlice[T any](ctx context.Context, p *TracerConnection, models []T, opts ...option) (err error) {
+	var ptrs []*T
+	for k := range models {
+		ptrs = append(ptrs, &models[k])
+	}
+	return Upsert(ctx, p, ptrs, opts...)
+}
+
+// Upsert batch-inserts the given models and updates the existing rows on a
+// conflict with the key columns. It uses ON CONFLICT ... DO UPDATE on
+// PostgreSQL, CockroachDB, and SQLite, and ON DUPLICATE KEY UPDATE on MySQL.
+// If the models exceed the dialect's placeholder limit, they are upserted in
+// chunks.
+//
+// PostgreSQL rejects statements which update the same row twice, so the
+// models must not contain duplicate keys. On PostgreSQL, CockroachDB, and
+// SQLite, the key columns must be covered by a unique index. With the default
+// key, a model whose ID belongs to a row of another network therefore fails
+// with a unique violation instead of updating that row.
+//
+// On MySQL, rows of other networks are left unchanged instead. MySQL does not
+// report which rows were updated, so the IDs of updated rows are not written
+// back to the models; a model which conflicted on a unique index other than
+// the primary key keeps the ID it was given, not the ID of the updated row.
+func Upsert[T any](ctx context.Context, p *TracerConnection, models []*T, opts ...option) (err error) {
+	ctx, span := p.Tracer.Tracer().Start(ctx, "persistence.sql.batch.Upsert")
+	defer otelx.End(span, &err)
+
+	if len(models) == 0 {
+		return nil
+	}
+
+	options := &createOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	conn := p.Connection
+	quoter, ok := conn.Dialect.(quoter)
+	if !ok {
+		return errors.Errorf("store is not a quoter: %T", conn.Store)
+	}
+
+	var v T
+	keys, updates, err := keyAndUpdateColumns(pop.NewModel(v, ctx), options)
+	if err != nil {
+		return err
+	}
+
+	clause := upsertClause(conn.Dialect.Name(), quoter, keys, updates)
+	for chunk := range chunks(ctx, conn, models) {
+		if err := insert(ctx, conn, chunk, clause); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func upsertClause(dialect string, quoter quoter, keys, updates []string) string {
+	set := make([]string, len(updates))
+	if dialect == dbal.DriverMySQL {
+		for i, c := range updates {
+			if slices.Contains(keys, "nid") {
+				// MySQL ignores the conflict target, so the row may belong to
+				// another network, which must not be overwritten.
+				set[i] = fmt.Sprintf("%s = IF(%s = VALUES(%s), VALUES(%s), %s)", quoter.Quote(c), quoter.Quote("nid"), quoter.Quote("nid"), quoter.Quote(c), quoter.Quote(c))
+				continue
+			}
+			set[i] = fmt.Sprintf("%s = VALUES(%s)", quoter.Quote(c), quoter.Quote(c))
+		}
+		return "ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+	}
+
+	quotedKeys := make([]string, len(keys))
+	for i, c := range keys {
+		quotedKeys[i] = quoter.Quote(c)
+	}
+	for i, c := range updates {
+		set[i] = fmt.Sprintf("%s = EXCLUDED.%s", quoter.Quote(c), quoter.Quote(c))
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(quotedKeys, ", "), strings.Join(set, ", "))
+}
+
+// keyAndUpdateColumns returns the configured key and update columns, or their
+// defaults, and validates that they are columns of the model.
+func keyAndUpdateColumns(model *pop.Model, options *createOptions) (keys, updates []string, err error) {
+	var columns []string
+	for _, col := range model.Columns().Cols {
+		columns = append(columns, col.Name)
+	}
+	// We sort for the sole reason that the generated queries are deterministic.
+	slices.Sort(columns)
+
+	keys = options.keyColumns
+	if len(keys) == 0 {
+		keys = []string{model.IDField()}
+		if model.IDField() != "nid" && slices.Contains(columns, "nid") {
+			keys = append(keys, "nid")
+		}
+	}
+
+	updates = options.updateColumns
+	if len(updates) == 0 {
+		for _, c := range columns {
+			if c != model.IDField() && c != "nid" && c != "created_at" && !slices.Contains(keys, c) {
+				updates = append(updates, c)
+			}
+		}
+	}
+	if len(updates) == 0 {
+		return nil, nil, errors.New("batch: no columns to update")
+	}
+
+	for _, c := range append(slices.Clone(keys), updates...) {
+		if !slices.Contains(columns, c) {
+			return nil, nil, errors.Errorf("batch: %q is not a column of table %s", c, model.TableName())
+		}
+	}
+
+	return keys, updates, nil
+}